@@ -0,0 +1,85 @@
+// Command golinks-migrate copies every link from one store to another.
+//
+// The source and destination stores are configured with the same environment
+// variables as the server, prefixed with SOURCE_ and DEST_, for example
+// SOURCE_STORE_TYPE=file and DEST_STORE_TYPE=postgres.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/migrate"
+	"github.com/imdevinc/go-links/internal/store"
+	_ "github.com/joho/godotenv/autoload"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would be migrated without writing to the destination")
+	conflict := flag.String("conflict", string(migrate.ConflictSkip), "what to do when a link already exists in the destination: skip, overwrite or fail")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx := context.Background()
+
+	policy, err := migrate.ParseConflictPolicy(*conflict)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(2)
+	}
+
+	srcCfg, err := config.StoreFromEnv(ctx, "SOURCE_")
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	dstCfg, err := config.StoreFromEnv(ctx, "DEST_")
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// the source is never created, so a mistyped source fails instead of
+	// migrating nothing
+	src, err := store.Open(ctx, srcCfg, store.Options{MustExist: true})
+	if err != nil {
+		logger.Error("failed to open source store", "error", err)
+		os.Exit(1)
+	}
+	// a dry run only reads the destination, and a destination that does not
+	// exist yet is empty
+	var dst store.Store
+	if *dryRun {
+		dst, err = store.Open(ctx, dstCfg, store.Options{ReadOnly: true})
+		if errors.Is(err, store.ErrStoreNotFound) {
+			logger.Info("destination store does not exist yet", "error", err)
+			dst, err = store.NewMemoryStore(), nil
+		}
+	} else {
+		dst, err = store.New(ctx, dstCfg)
+	}
+	if err != nil {
+		src.Close(ctx)
+		logger.Error("failed to open destination store", "error", err)
+		os.Exit(1)
+	}
+
+	report, err := migrate.Run(ctx, src, dst, migrate.Options{Conflict: policy, DryRun: *dryRun})
+	src.Close(ctx)
+	dst.Close(ctx)
+	logger.Info("migration finished",
+		"dry_run", *dryRun,
+		"read", report.Read,
+		"created", report.Created,
+		"overwritten", report.Overwritten,
+		"skipped", report.Skipped,
+	)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
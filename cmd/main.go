@@ -4,49 +4,133 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/imdevinc/go-links/internal/app"
 	"github.com/imdevinc/go-links/internal/config"
 	"github.com/imdevinc/go-links/internal/store"
+	"github.com/imdevinc/go-links/internal/tracing"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	cfg, err := config.FromEnv(ctx)
-	if err != nil {
+	if err := run(ctx, logger); err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
+}
 
-	var s store.Store
-	switch cfg.StoreType {
-	case config.StoreTypeFile:
-		s, err = store.NewFileStore("links.json", true)
-	case config.StoreTypeMemory:
-		s = store.NewMemoryStore()
-	case config.StoreTypeMongo:
-		s, err = store.NewMongoDBStore(ctx, cfg.Mongo.Username, cfg.Mongo.Password, cfg.Mongo.Host, cfg.Mongo.DatabaseName)
-	case config.StoreTypePostgres:
-		s, err = store.NewPostgresStore(ctx, cfg.Postgres.Username, cfg.Postgres.Password, cfg.Postgres.Host, cfg.Postgres.DatabaseName)
+// run serves requests until ctx is canceled, then waits for in-flight requests
+// before closing the store, which writes any buffered view counts.
+func run(ctx context.Context, logger *slog.Logger) error {
+	cfg, err := config.FromEnv(ctx)
+	if err != nil {
+		return err
 	}
 
+	// the tracer provider is set up before the store so the store drivers
+	// trace their queries with it
+	var tracerProvider trace.TracerProvider
+	if cfg.Tracing.Enabled {
+		provider, err := tracing.NewProvider(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+			defer cancel()
+			if err := provider.Shutdown(shutdownCtx); err != nil {
+				logger.Error(err.Error())
+			}
+		}()
+		tracerProvider = provider
+	}
+
+	s, err := store.New(ctx, cfg.StoreConfig)
 	if err != nil {
-		logger.Error(err.Error())
-		os.Exit(1)
+		return err
 	}
 
-	defer s.Close(ctx)
+	analytics, _ := s.(store.Analytics)
+	subscriber, _ := s.(store.Subscriber)
+	tokens, _ := s.(store.Tokens)
+	audit, _ := s.(store.Audit)
+	revisions, _ := s.(store.Revisions)
 
-	server := app.App{
-		Store:  s,
-		Logger: logger,
+	var registry *prometheus.Registry
+	if cfg.Metrics.Enabled {
+		registry = prometheus.NewRegistry()
+		registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+		s, err = store.NewInstrumentedStore(s, registry)
+		if err != nil {
+			return err
+		}
+	}
+	if tracerProvider != nil {
+		s = store.NewTracedStore(s, tracerProvider)
+	}
+	if cfg.ViewFlushInterval > 0 {
+		s = store.NewBufferedViewStore(s, cfg.ViewFlushInterval, logger)
 	}
 
-	if err := server.Start(ctx, &cfg); err != nil {
-		logger.Error(err.Error())
-		os.Exit(1)
+	var cache *store.Cached
+	if cfg.Cache.Size > 0 {
+		cache = store.NewCachedStore(s, store.CacheOptions{
+			Size:        cfg.Cache.Size,
+			TTL:         cfg.Cache.TTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+		})
+		s = cache
+		if subscriber != nil {
+			changes, err := subscriber.Subscribe(ctx)
+			if err != nil {
+				logger.With("error", err).Warn("not following link changes, other replicas' changes are cached until they expire")
+			} else {
+				go cache.Follow(changes)
+			}
+		}
+	}
+
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := s.Close(closeCtx); err != nil {
+			logger.Error(err.Error())
+		}
+	}()
+
+	if analytics != nil && cfg.ClickFlushInterval > 0 {
+		clicks := store.NewBufferedClicks(analytics, cfg.ClickFlushInterval, logger)
+		analytics = clicks
+		// deferred after the store Close above so pending clicks are written first
+		defer func() {
+			closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+			defer cancel()
+			if err := clicks.Close(closeCtx); err != nil {
+				logger.Error(err.Error())
+			}
+		}()
 	}
+
+	server := app.App{
+		Store:     s,
+		Analytics: analytics,
+		Tokens:    tokens,
+		Audit:     audit,
+		Revisions: revisions,
+		Cache:     cache,
+		Metrics:   registry,
+		Tracing:   tracerProvider,
+		Logger:    logger,
+	}
+
+	return server.Start(ctx, &cfg)
 }
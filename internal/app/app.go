@@ -15,19 +15,42 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/crewjam/saml/samlsp"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 	"github.com/imdevinc/go-links/internal/config"
 	"github.com/imdevinc/go-links/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type App struct {
-	Store  store.Store
-	Logger *slog.Logger
-	config *config.Config
-	sp     *samlsp.Middleware
+	Store     store.Store
+	Analytics store.Analytics
+	// Tokens stores personal access tokens, if the store supports them.
+	Tokens store.Tokens
+	// Audit records link changes, if the store supports it.
+	Audit store.Audit
+	// Revisions records the history of each link, if the store supports it.
+	Revisions store.Revisions
+	// Cache is the link cache wrapping Store, if enabled.
+	Cache *store.Cached
+	// Metrics is the registry served at /metrics, nil disables metrics.
+	Metrics *prometheus.Registry
+	// Tracing records a span for every request, nil disables tracing.
+	Tracing trace.TracerProvider
+	Logger  *slog.Logger
+	config  *config.Config
+	sp      *samlsp.Middleware
+	oidc    *oidcAuth
+	proxy   *proxyAuth
+	metrics *appMetrics
+	hosts   *hosts
+	// shuttingDown fails readiness checks once the server starts shutting down.
+	shuttingDown atomic.Bool
 }
 
 type GetLinksType string
@@ -38,6 +61,16 @@ const (
 	Owned   GetLinksType = "owned"
 )
 
+// staticAssets are the files of the web UI served from the root of StaticPath.
+var staticAssets = []string{
+	"/asset-manifest.json",
+	"/favicon.ico",
+	"/logo192.png",
+	"/logo512.png",
+	"/manifest.json",
+	"/robots.txt",
+}
+
 var staticRegexp = regexp.MustCompile(`^static(\/.*)?|api\/popular|api\/recent`)
 var validLinkRegexp = regexp.MustCompile(`^[a-zA-Z0-9\/\-]*$`)
 
@@ -59,19 +92,40 @@ func (a *App) Start(ctx context.Context, cfg *config.Config) error {
 	}
 	a.config = cfg
 
-	sp, err := a.configureSaml()
-	if err != nil && a.config.SSO.Require {
-		return fmt.Errorf("failed to configure saml: %w", err)
-	}
-	a.sp = sp
-	authWrapper := func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if sp == nil {
-				next.ServeHTTP(w, r)
-			} else {
-				sp.RequireAccount(next).ServeHTTP(w, r)
-			}
-		})
+	switch cfg.AuthMode {
+	case config.AuthModeSAML:
+		sp, err := a.configureSaml()
+		if err != nil && a.config.SSO.Require {
+			return fmt.Errorf("failed to configure saml: %w", err)
+		}
+		a.sp = sp
+	case config.AuthModeOIDC:
+		oidc, err := newOIDCAuth(ctx, cfg.OIDC, cfg.FQDN, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to configure oidc: %w", err)
+		}
+		a.oidc = oidc
+	case config.AuthModeProxy:
+		proxy, err := newProxyAuth(cfg.Proxy, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to configure proxy auth: %w", err)
+		}
+		a.proxy = proxy
+	case config.AuthModeNone:
+	default:
+		return fmt.Errorf("unknown auth mode %q", cfg.AuthMode)
+	}
+	hosts, err := newHosts(cfg.FQDN, cfg.Hosts)
+	if err != nil {
+		return fmt.Errorf("failed to configure hosts: %w", err)
+	}
+	a.hosts = hosts
+	sp := a.sp
+	if cfg.TrashRetention > 0 {
+		go a.purgeTrash(ctx, cfg.TrashRetention)
+	}
+	if a.Analytics != nil && cfg.ClickRetention > 0 {
+		go a.purgeClicks(ctx, cfg.ClickRetention)
 	}
 
 	r := mux.NewRouter()
@@ -83,35 +137,162 @@ func (a *App) Start(ctx context.Context, cfg *config.Config) error {
 	if sp != nil {
 		r.PathPrefix("/saml/").Handler(sp)
 	}
-	r.PathPrefix("/api").Handler(authWrapper(http.HandlerFunc(a.handleApi)))
-	r.Path("/").Handler(authWrapper(fs))
-	r.PathPrefix("/static").Methods(http.MethodGet).Handler(authWrapper(fs))
-	assets := []string{
-		"/asset-manifest.json",
-		"/favicon.ico",
-		"/logo192.png",
-		"/logo512.png",
-		"/manifest.json",
-		"/robots.txt",
-	}
-	for _, a := range assets {
-		r.Path(a).Handler(authWrapper(fs))
-	}
-	r.PathPrefix("/static").Handler(authWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if a.oidc != nil {
+		r.PathPrefix("/oidc/").Handler(a.oidc)
+	}
+	r.PathPrefix("/api").Handler(a.authWrapper(http.HandlerFunc(a.handleApi)))
+	r.Path("/").Handler(a.authWrapper(fs))
+	r.PathPrefix("/static").Methods(http.MethodGet).Handler(a.authWrapper(fs))
+	for _, asset := range staticAssets {
+		r.Path(asset).Handler(a.authWrapper(fs))
+	}
+	r.PathPrefix("/static").Handler(a.authWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Protected route"})
 	})))
-	r.Path("/{link:.*}").Handler(authWrapper(http.HandlerFunc(a.handleLink)))
-	a.Logger.With("port", cfg.Port).Info("starting server")
-	return http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), r)
+	r.Path("/{link:.*}").Handler(a.authWrapper(http.HandlerFunc(a.handleLink)))
+
+	// /metrics and the health checks are served outside the router so they
+	// are not authenticated or redirected to FQDN like other requests
+	var handler http.Handler = r
+	if a.Tracing != nil {
+		handler = a.traced(handler)
+	}
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", a.handleHealthz)
+	root.HandleFunc("/readyz", a.handleReadyz)
+	servers := []*http.Server{}
+	if a.Metrics == nil {
+		root.Handle("/", handler)
+	} else {
+		metrics, err := newAppMetrics(a.Metrics, a.Store, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to register metrics: %w", err)
+		}
+		a.metrics = metrics
+		root.Handle("/", metrics.instrument(handler))
+		metricsHandler := promhttp.HandlerFor(a.Metrics, promhttp.HandlerOpts{})
+		if cfg.Metrics.Port == 0 {
+			root.Handle("/metrics", metricsHandler)
+		} else {
+			servers = append(servers, a.serveMetrics(cfg.Metrics.Port, metricsHandler))
+		}
+	}
+	server := a.newServer(cfg.Port, root)
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return fmt.Errorf("both tls cert file and key file must be set")
+		}
+		certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+		go certs.watch(ctx, certReloadInterval)
+		server.TLSConfig = &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+		if cfg.TLS.HSTS.MaxAge > 0 {
+			server.Handler = hstsHandler(cfg.TLS.HSTS, root)
+		}
+		if cfg.TLS.HTTPPort != 0 {
+			servers = append(servers, a.serveHTTPSRedirects(cfg.TLS.HTTPPort))
+		}
+	}
+	servers = append(servers, server)
+	errs := make(chan error, 1)
+	go func() {
+		a.Logger.With("port", cfg.Port, "tls", server.TLSConfig != nil).Info("starting server")
+		if server.TLSConfig != nil {
+			errs <- server.ListenAndServeTLS("", "")
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	// readiness fails first while requests are still served, giving load
+	// balancers the drain delay to stop routing here before the listeners
+	// close
+	a.Logger.With("delay", cfg.Server.DrainDelay).Info("draining server")
+	a.shuttingDown.Store(true)
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(cfg.Server.DrainDelay):
+	}
+
+	// in-flight requests are finished before Start returns, so the store can
+	// be closed afterwards
+	a.Logger.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	var shutdownErrs []error
+	for _, srv := range servers {
+		shutdownErrs = append(shutdownErrs, srv.Shutdown(shutdownCtx))
+	}
+	return errors.Join(shutdownErrs...)
+}
+
+// newServer creates a server for handler on port, with the configured timeouts.
+func (a *App) newServer(port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: a.config.Server.ReadTimeout,
+		ReadTimeout:       a.config.Server.ReadTimeout,
+		WriteTimeout:      a.config.Server.WriteTimeout,
+		IdleTimeout:       a.config.Server.IdleTimeout,
+	}
+}
+
+// serveMetrics serves /metrics on its own port until the returned server is
+// shut down.
+func (a *App) serveMetrics(port int, handler http.Handler) *http.Server {
+	metrics := http.NewServeMux()
+	metrics.Handle("/metrics", handler)
+	server := a.newServer(port, metrics)
+	go func() {
+		a.Logger.With("port", port).Info("starting metrics server")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.Logger.With("port", port).Error(err.Error())
+		}
+	}()
+	return server
+}
+
+// authWrapper authenticates requests with a personal access token when one
+// is given, otherwise with the configured auth mode.
+func (a *App) authWrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := bearerToken(r); ok && a.Tokens != nil {
+			a.requireToken(next).ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case a.sp != nil:
+			a.sp.RequireAccount(a.withIdentity(next)).ServeHTTP(w, r)
+		case a.oidc != nil:
+			a.oidc.RequireAccount(next).ServeHTTP(w, r)
+		case a.proxy != nil:
+			a.proxy.RequireAccount(next).ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
 }
 
 func (a *App) indexHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Host == a.config.FQDN {
+		if a.hosts.accepts(r.Host) {
 			next.ServeHTTP(w, r)
 		} else {
-			w.Header().Set("Location", fmt.Sprintf("//%s%s", a.config.FQDN, r.URL.Path))
-			w.WriteHeader(http.StatusMovedPermanently)
+			// the scheme is left out so the client keeps using the one it used
+			w.Header().Set("Location", fmt.Sprintf("//%s%s", a.config.FQDN, r.URL.RequestURI()))
+			w.WriteHeader(a.hosts.redirectCode(r.Method))
 		}
 	})
 }
@@ -119,7 +300,7 @@ func (a *App) indexHandler(next http.Handler) http.Handler {
 func corsHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "content-type")
+		w.Header().Set("Access-Control-Allow-Headers", "authorization, content-type")
 		next.ServeHTTP(w, r)
 	})
 }
@@ -128,6 +309,15 @@ func (a *App) handleLink(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	// static/ is protected due to web display resources
 	v := mux.Vars(r)
+	if r.Method == http.MethodGet {
+		// lookups may carry extra path segments that are passed to the target
+		if staticRegexp.MatchString(strings.ToLower(strings.Trim(v["link"], "/"))) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		a.handleGetLink(w, r)
+		return
+	}
 	if link, ok := v["link"]; ok {
 		link, err := cleanLink(link)
 		if err != nil {
@@ -140,10 +330,10 @@ func (a *App) handleLink(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	switch r.Method {
-	case http.MethodGet:
-		a.handleGetLink(w, r)
 	case http.MethodPost:
 		a.handleCreateLink(w, r)
+	case http.MethodPut, http.MethodPatch:
+		a.handleUpdateLink(w, r)
 	case http.MethodDelete:
 		a.handleDeleteLink(w, r)
 	case http.MethodOptions:
@@ -154,12 +344,14 @@ func (a *App) handleLink(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) handleGetLink(w http.ResponseWriter, r *http.Request) {
-	link, err := cleanLink(mux.Vars(r)["link"])
-	if err != nil {
-		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
-		return
-	}
-	result, err := a.Store.GetLinkByName(r.Context(), link)
+	// links in the host's namespace take precedence over links of the same name
+	path := mux.Vars(r)["link"]
+	namespace := a.hosts.namespace(r.Host)
+	result, args, err := resolveLinkIn(r.Context(), a.Store, namespace, path)
+	if errors.Is(err, store.ErrLinkNotFound) && namespace != "" {
+		result, args, err = resolveLink(r.Context(), a.Store, path)
+	}
+	a.metrics.redirect(err == nil)
 	if err != nil {
 		if !errors.Is(err, store.ErrLinkNotFound) {
 			a.Logger.Error(err.Error())
@@ -172,7 +364,8 @@ func (a *App) handleGetLink(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		a.Logger.Error(err.Error())
 	}
-	http.Redirect(w, r, result.URL, http.StatusFound)
+	a.recordClick(r, result)
+	http.Redirect(w, r, expandLinkURL(result.URL, args, r.URL.RawQuery), http.StatusFound)
 }
 
 func (a *App) handleCreateLink(w http.ResponseWriter, r *http.Request) {
@@ -198,6 +391,11 @@ func (a *App) handleCreateLink(w http.ResponseWriter, r *http.Request) {
 	clean, err := cleanLink(mux.Vars(r)["link"])
 	if err != nil {
 		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateURL(link.URL); err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
 	}
 	link.Name = clean
 	link.CreatedBy = email
@@ -211,21 +409,141 @@ func (a *App) handleCreateLink(w http.ResponseWriter, r *http.Request) {
 		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
 		return
 	}
+	created := a.currentLink(r.Context(), link.Name, link)
+	a.recordAudit(r, store.AuditActionCreate, link.Name, nil, created)
+	a.recordRevision(r, nil, *created)
 	w.WriteHeader(http.StatusCreated)
 }
 
+func (a *App) handleUpdateLink(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	name, err := cleanLink(mux.Vars(r)["link"])
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	patch, err := store.CreateLinkPatchFromPayload(body)
+	if err != nil || (patch.URL != nil && *patch.URL == "") {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid payload"})
+		return
+	}
+	if patch.URL != nil {
+		if err := validateURL(*patch.URL); err != nil {
+			sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	existing, err := a.Store.GetLinkByName(r.Context(), name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	if !a.canModifyLink(identity, existing) {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "only the link owner can edit this link"})
+		return
+	}
+	err = a.Store.UpdateLink(r.Context(), existing.Name, patch)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	updated := a.currentLink(r.Context(), existing.Name, existing)
+	a.recordAudit(r, store.AuditActionUpdate, existing.Name, &existing, updated)
+	a.recordRevision(r, &existing, *updated)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canModifyLink reports whether identity owns link or is an admin.
+func (a *App) canModifyLink(identity Identity, link store.Link) bool {
+	return isOwner(identity, link) || a.isAdmin(identity)
+}
+
+func isOwner(identity Identity, link store.Link) bool {
+	return link.OwnedBy(identity.owners())
+}
+
+// isAdmin reports whether identity is listed in ADMINS or belongs to one of
+// the ADMIN_GROUPS.
+func (a *App) isAdmin(identity Identity) bool {
+	for _, admin := range a.config.Admins {
+		if strings.EqualFold(admin, identity.Email) {
+			return true
+		}
+	}
+	for _, group := range identity.Groups {
+		for _, admin := range a.config.AdminGroups {
+			if strings.EqualFold(admin, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (a *App) handleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
 	name, err := cleanLink(mux.Vars(r)["link"])
 	if err != nil {
 		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
-	err = a.Store.DisableLink(r.Context(), name)
+	existing, err := a.Store.GetLinkByName(r.Context(), name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	if !a.canModifyLink(identity, existing) {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "only the link owner can delete this link"})
+		return
+	}
+	err = a.Store.DisableLink(r.Context(), existing.Name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
 	if err != nil {
 		a.Logger.Error(err.Error())
 		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
 		return
 	}
+	// disabled links can not be read back, so the snapshot is built here
+	disabled := existing
+	disabledAt := time.Now()
+	disabled.Disabled = true
+	disabled.DisabledAt = &disabledAt
+	a.recordAudit(r, store.AuditActionDisable, existing.Name, &existing, &disabled)
 	w.WriteHeader(http.StatusAccepted)
 }
 
@@ -244,13 +562,13 @@ func (a *App) handleGetLinkList(t GetLinksType) http.HandlerFunc {
 		case Recent:
 			links, err = a.Store.GetRecentLinks(r.Context(), 10)
 		case Owned:
-			email, err := a.getEmailFromRequest(r)
+			identity, err := a.getIdentityFromRequest(r)
 			if err != nil {
 				a.Logger.Error(err.Error())
 				sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
 				return
 			}
-			links, err = a.Store.GetOwnedLinks(r.Context(), email)
+			links, err = a.Store.GetOwnedLinks(r.Context(), identity.owners())
 			if err != nil {
 				a.Logger.Error(err.Error())
 				sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "internal server error"})
@@ -314,7 +632,48 @@ func (a *App) handleApi(w http.ResponseWriter, r *http.Request) {
 		a.handleGetLinkList(Owned)(w, r)
 	case "/api/query":
 		a.handleQueryLinks(w, r)
+	case "/api/trash":
+		a.handleGetTrash(w, r)
+	case "/api/export":
+		a.handleExport(w, r)
+	case "/api/import":
+		a.handleImport(w, r)
+	case "/api/cache":
+		a.handleGetCacheStats(w, r)
+	case "/api/tokens":
+		a.handleTokens(w, r)
+	case "/api/audit":
+		a.handleGetAudit(w, r)
 	default:
+		path := strings.ToLower(r.URL.Path)
+		if strings.HasPrefix(path, "/api/tokens/") {
+			a.handleDeleteToken(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/api/trash/") {
+			a.handleTrashedLink(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/api/links/") && strings.HasSuffix(path, "/stats") {
+			a.handleGetLinkStats(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/api/links/") && strings.HasSuffix(path, "/owners") {
+			a.handleLinkOwners(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/api/links/") && strings.HasSuffix(path, "/transfer") {
+			a.handleTransferLink(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/api/links/") && strings.HasSuffix(path, "/history") {
+			a.handleLinkHistory(w, r)
+			return
+		}
+		if strings.HasPrefix(path, "/api/links/") && strings.HasSuffix(path, "/rollback") {
+			a.handleRollbackLink(w, r)
+			return
+		}
 		sendError(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
 	}
 }
@@ -355,21 +714,23 @@ func (a *App) configureSaml() (*samlsp.Middleware, error) {
 }
 
 func (a *App) getEmailFromRequest(r *http.Request) (string, error) {
-	if a.sp == nil {
-		return "untracked", nil
-	}
-	c, err := r.Cookie("token")
+	identity, err := a.getIdentityFromRequest(r)
 	if err != nil {
-		return "", fmt.Errorf("failed to get cookie: %w", err)
+		return "", err
 	}
-	token, _ := jwt.Parse(c.Value, func(t *jwt.Token) (interface{}, error) {
-		return nil, nil
-	})
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("failed to parse claims")
+	return identity.Email, nil
+}
+
+// getIdentityFromRequest returns the authenticated user, or an "untracked"
+// user when no auth mode is configured.
+func (a *App) getIdentityFromRequest(r *http.Request) (Identity, error) {
+	if identity, ok := IdentityFromContext(r.Context()); ok {
+		return identity, nil
+	}
+	if a.sp == nil && a.oidc == nil && a.proxy == nil {
+		return Identity{Email: "untracked"}, nil
 	}
-	return claims.GetSubject()
+	return Identity{}, fmt.Errorf("no valid session for request")
 }
 
 func (a *App) handleQueryLinks(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkOwnership(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{
+		TrustedCIDRs: []string{"10.0.0.1"},
+		EmailHeader:  "X-Forwarded-Email",
+		GroupsHeader: "X-Forwarded-Groups",
+	}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	cases := []struct {
+		Name           string
+		Email          string
+		Groups         string
+		Method         string
+		ExpectedStatus int
+		Override       bool
+	}{
+		{Name: "Owner updates", Email: "Owner@example.com", Method: http.MethodPatch, ExpectedStatus: http.StatusNoContent},
+		{Name: "Owner deletes", Email: "owner@example.com", Method: http.MethodDelete, ExpectedStatus: http.StatusAccepted},
+		{Name: "Other user updates", Email: "other@example.com", Method: http.MethodPatch, ExpectedStatus: http.StatusForbidden},
+		{Name: "Other user deletes", Email: "other@example.com", Groups: "engineering", Method: http.MethodDelete, ExpectedStatus: http.StatusForbidden},
+		{Name: "Admin deletes", Email: "admin@example.com", Method: http.MethodDelete, ExpectedStatus: http.StatusAccepted, Override: true},
+		{Name: "Admin group member updates", Email: "other@example.com", Groups: "engineering, Link-Admins", Method: http.MethodPatch, ExpectedStatus: http.StatusNoContent, Override: true},
+		{Name: "Admin group member deletes", Email: "other@example.com", Groups: "link-admins", Method: http.MethodDelete, ExpectedStatus: http.StatusAccepted, Override: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			assert.NoError(t, s.CreateLink(context.Background(), store.Link{Name: "docs", URL: "https://example.com", CreatedBy: "owner@example.com"}))
+			a := App{Store: s, Audit: s, Logger: logger, proxy: auth, config: &config.Config{
+				Admins:      []string{"admin@example.com"},
+				AdminGroups: []string{"link-admins"},
+			}}
+			router := mux.NewRouter()
+			router.Path("/{link:.*}").Handler(a.authWrapper(http.HandlerFunc(a.handleLink)))
+
+			r := httptest.NewRequest(tc.Method, "/docs", strings.NewReader(`{"url": "https://example.com/new"}`))
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header.Set("X-Forwarded-Email", tc.Email)
+			r.Header.Set("X-Forwarded-Groups", tc.Groups)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tc.ExpectedStatus, w.Code)
+
+			_, err := s.GetLinkByName(context.Background(), "docs")
+			if tc.Method == http.MethodDelete && tc.ExpectedStatus == http.StatusAccepted {
+				assert.ErrorIs(t, err, store.ErrLinkNotFound)
+			} else {
+				assert.NoError(t, err)
+			}
+			// admin overrides are kept in the audit log
+			events, err := a.Audit.GetAuditEvents(context.Background(), store.AuditFilter{Link: "docs"})
+			assert.NoError(t, err)
+			if tc.ExpectedStatus == http.StatusForbidden {
+				assert.Empty(t, events)
+			} else if assert.Len(t, events, 1) {
+				assert.Equal(t, tc.Override, events[0].AdminOverride)
+			}
+		})
+	}
+}
+
+func TestLinkOwners(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{
+		TrustedCIDRs: []string{"10.0.0.1"},
+		EmailHeader:  "X-Forwarded-Email",
+		GroupsHeader: "X-Forwarded-Groups",
+	}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := store.NewMemoryStore()
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com", CreatedBy: "owner@example.com"}))
+	a := App{Store: s, Logger: logger, proxy: auth, config: &config.Config{}}
+	handler := a.authWrapper(http.HandlerFunc(a.handleApi))
+	serve := func(method string, target string, body string, email string, groups string) int {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-Email", email)
+		r.Header.Set("X-Forwarded-Groups", groups)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	owned := func(email string, groups ...string) []string {
+		links, err := s.GetOwnedLinks(ctx, store.OwnerPrincipals(email, groups))
+		assert.NoError(t, err)
+		return names(links)
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/links/docs/owners", `{"owner": "other@example.com"}`, "other@example.com", ""))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/links/docs/owners", `{"owner": "group:engineering"}`, "owner@example.com", ""))
+	assert.Equal(t, []string{"docs"}, owned("other@example.com", "engineering"))
+
+	// group members can manage the link, including its owners
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/links/docs/owners", `{"owner": "co-owner@example.com"}`, "other@example.com", "engineering"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/links/docs/owners", `{"owner": "Co-Owner@example.com"}`, "owner@example.com", ""))
+	link, _ := s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, []string{"owner@example.com", "group:engineering", "co-owner@example.com"}, link.Owners)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPatch, "/api/links/docs/owners", `{}`, "owner@example.com", ""))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/api/links/docs/owners", `{"owner": "group:Engineering"}`, "co-owner@example.com", ""))
+	assert.Empty(t, owned("other@example.com", "engineering"))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/api/links/docs/owners", `{"owner": "nobody@example.com"}`, "owner@example.com", ""))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/links/docs/owners", `{"owner": " "}`, "owner@example.com", ""))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/links/docs/transfer", `{"owner": "new@example.com"}`, "co-owner@example.com", ""))
+	assert.Equal(t, []string{"docs"}, owned("new@example.com"))
+	assert.Empty(t, owned("owner@example.com"))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/links/docs/transfer", `{"owner": "owner@example.com"}`, "owner@example.com", ""))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, "/api/links/docs/owners", `{"owner": "new@example.com"}`, "new@example.com", ""))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/api/links/missing/transfer", `{"owner": "new@example.com"}`, "new@example.com", ""))
+}
+
+func names(links []store.Link) []string {
+	names := []string{}
+	for _, link := range links {
+		names = append(names, link.Name)
+	}
+	return names
+}
+
+func TestCreateLinkInvalidName(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{TrustedCIDRs: []string{"10.0.0.1"}, EmailHeader: "X-Forwarded-Email"}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := store.NewMemoryStore()
+	a := App{Store: s, Logger: logger, proxy: auth, config: &config.Config{}}
+	handler := a.authWrapper(http.HandlerFunc(a.handleCreateLink))
+
+	r := httptest.NewRequest(http.MethodPost, "/-docs", strings.NewReader(`{"url": "https://example.com"}`))
+	r = mux.SetURLVars(r, map[string]string{"link": "/-docs"})
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-Email", "owner@example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	count := 0
+	assert.NoError(t, s.WalkLinks(context.Background(), func(store.Link) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 0, count, "no link is created for an invalid name")
+}
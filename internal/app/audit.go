@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+const defaultAuditLimit = 100
+const maxAuditLimit = 1000
+
+// recordAudit stores an audit event for a change to the link called name by
+// the caller of r. Before is nil for new links and after is nil for purged
+// links. Changes by admins to links they do not own are marked as admin
+// overrides. Failures are logged and never fail the change itself.
+func (a *App) recordAudit(r *http.Request, action store.AuditAction, name string, before *store.Link, after *store.Link) {
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		return
+	}
+	subject := before
+	if subject == nil {
+		subject = after
+	}
+	override := a.isAdmin(identity) && (subject == nil || !isOwner(identity, *subject))
+	if override {
+		a.Logger.With("admin", identity.Email, "action", action, "link", name).Warn("admin override")
+	}
+	if a.Audit == nil {
+		return
+	}
+	event := store.AuditEvent{
+		ID:            randomString()[:16],
+		Time:          time.Now().UTC(),
+		Actor:         identity.Email,
+		Action:        action,
+		Link:          name,
+		Before:        before,
+		After:         after,
+		ClientIP:      a.clientIP(r),
+		AdminOverride: override,
+	}
+	if err := a.Audit.RecordAuditEvent(r.Context(), event); err != nil {
+		a.Logger.With("action", action, "link", name).Error(err.Error())
+	}
+}
+
+// currentLink returns the stored state of the link called name after a
+// change, falling back to link if it can not be read back.
+func (a *App) currentLink(ctx context.Context, name string, link store.Link) *store.Link {
+	if a.Audit == nil && a.Revisions == nil {
+		return &link
+	}
+	current, err := a.Store.GetLinkByName(ctx, name)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		return &link
+	}
+	return &current
+}
+
+// clientIP returns the address of the client making r. X-Forwarded-For is
+// only believed from the trusted proxies of the proxy auth mode.
+func (a *App) clientIP(r *http.Request) string {
+	if a.proxy != nil && a.proxy.isTrusted(r.RemoteAddr) {
+		if forwarded, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ","); strings.TrimSpace(forwarded) != "" {
+			return strings.TrimSpace(forwarded)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleGetAudit serves /api/audit?link=&actor=&since=&until=&limit=N to
+// admins. Times are RFC 3339 and the range includes since but not until.
+func (a *App) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	if !a.isAdmin(identity) {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "only admins can view the audit log"})
+		return
+	}
+	if a.Audit == nil {
+		sendError(w, http.StatusNotImplemented, ErrorResponse{Error: "the audit log is not supported by this store"})
+		return
+	}
+	query := r.URL.Query()
+	filter := store.AuditFilter{
+		Link:  query.Get("link"),
+		Actor: query.Get("actor"),
+		Limit: defaultAuditLimit,
+	}
+	for param, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(param); v != "" {
+			*value, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid " + param + " time, expected RFC 3339"})
+				return
+			}
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+			sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+			return
+		}
+	}
+	events, err := a.Audit.GetAuditEvents(r.Context(), filter)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	err = json.NewEncoder(w).Encode(events)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+}
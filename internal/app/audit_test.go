@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{TrustedCIDRs: []string{"10.0.0.1"}, EmailHeader: "X-Forwarded-Email"}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := store.NewMemoryStore()
+	a := App{Store: s, Audit: s, Logger: logger, proxy: auth, config: &config.Config{Admins: []string{"admin@example.com"}}}
+	router := mux.NewRouter()
+	router.PathPrefix("/api/").Handler(a.authWrapper(http.HandlerFunc(a.handleApi)))
+	router.Path("/{link:.*}").Handler(a.authWrapper(http.HandlerFunc(a.handleLink)))
+	serve := func(method string, target string, body string, email string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-Email", email)
+		r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	audit := func(query string) []store.AuditEvent {
+		w := serve(http.MethodGet, "/api/audit"+query, "", "admin@example.com")
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		events := []store.AuditEvent{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&events))
+		return events
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/docs", `{"url": "https://example.com/docs"}`, "owner@example.com").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPatch, "/docs", `{"url": "https://example.com/new"}`, "owner@example.com").Code)
+	assert.Equal(t, http.StatusAccepted, serve(http.MethodDelete, "/docs", "", "admin@example.com").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/api/trash/docs", "", "owner@example.com").Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/links/docs/owners", `{"owner": "other@example.com"}`, "owner@example.com").Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/wiki", `{"url": "https://example.com/wiki"}`, "other@example.com").Code)
+
+	events := audit("?link=DOCS")
+	actions := []store.AuditAction{}
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []store.AuditAction{store.AuditActionOwners, store.AuditActionRestore, store.AuditActionDisable, store.AuditActionUpdate, store.AuditActionCreate}, actions)
+	if len(events) == 5 {
+		created, updated, disabled := events[4], events[3], events[2]
+		assert.Equal(t, "owner@example.com", created.Actor)
+		assert.Equal(t, "203.0.113.7", created.ClientIP)
+		assert.Nil(t, created.Before)
+		if assert.NotNil(t, created.After) {
+			assert.Equal(t, []string{"owner@example.com"}, created.After.Owners)
+		}
+		if assert.NotNil(t, updated.Before) && assert.NotNil(t, updated.After) {
+			assert.Equal(t, "https://example.com/docs", updated.Before.URL)
+			assert.Equal(t, "https://example.com/new", updated.After.URL)
+		}
+		assert.False(t, updated.AdminOverride)
+		assert.Equal(t, "admin@example.com", disabled.Actor)
+		assert.True(t, disabled.AdminOverride)
+		if assert.NotNil(t, disabled.After) {
+			assert.True(t, disabled.After.Disabled)
+		}
+		if assert.NotNil(t, events[0].After) {
+			assert.Equal(t, []string{"owner@example.com", "other@example.com"}, events[0].After.Owners)
+		}
+	}
+
+	assert.Len(t, audit("?actor=other@example.com"), 1)
+	assert.Len(t, audit("?limit=2"), 2)
+	assert.Empty(t, audit("?since="+time.Now().Add(time.Hour).Format(time.RFC3339)))
+	assert.Len(t, audit("?until="+time.Now().Add(time.Hour).Format(time.RFC3339)), 6)
+
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/audit", "", "owner@example.com").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/api/audit?since=yesterday", "", "admin@example.com").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/api/audit?limit=0", "", "admin@example.com").Code)
+	a.Audit = nil
+	assert.Equal(t, http.StatusNotImplemented, serve(http.MethodGet, "/api/audit", "", "admin@example.com").Code)
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+type CacheStatsResponse struct {
+	store.CacheStats
+	HitRate float64 `json:"hit_rate"`
+}
+
+// handleGetCacheStats reports the link cache counters to admins.
+func (a *App) handleGetCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	if !a.isAdmin(identity) {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "only admins can view cache stats"})
+		return
+	}
+	if a.Cache == nil {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "cache is not enabled"})
+		return
+	}
+	stats := a.Cache.Stats()
+	err = json.NewEncoder(w).Encode(CacheStatsResponse{CacheStats: stats, HitRate: stats.HitRate()})
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+}
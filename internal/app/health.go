@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readyTimeout limits how long the store has to answer a readiness check.
+const readyTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// handleHealthz reports that the server is running. The store is not checked,
+// so an unavailable store does not get every replica restarted.
+func (a *App) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether the server can serve requests, which is when
+// the store answers a ping and the server is not shutting down.
+func (a *App) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if a.shuttingDown.Load() {
+		sendError(w, http.StatusServiceUnavailable, ErrorResponse{Error: "shutting down"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	if err := a.Store.Ping(ctx); err != nil {
+		a.Logger.With("error", err).Warn("store is not ready")
+		sendError(w, http.StatusServiceUnavailable, ErrorResponse{Error: "store is not ready"})
+		return
+	}
+	json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type unreachableStore struct {
+	store.Store
+}
+
+func (unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	serve := func(a *App, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	a := &App{Store: unreachableStore{store.NewMemoryStore()}, Logger: logger}
+	w := serve(a, a.handleHealthz)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+	w = serve(a, a.handleReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error": "store is not ready"}`, w.Body.String())
+
+	a = &App{Store: store.NewMemoryStore(), Logger: logger}
+	w = serve(a, a.handleReadyz)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+	a.shuttingDown.Store(true)
+	w = serve(a, a.handleReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusOK, serve(a, a.handleHealthz).Code)
+}
+
+func TestStartShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	base := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := &App{Store: store.NewMemoryStore(), Logger: logger}
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Start(ctx, &config.Config{
+			AuthMode: config.AuthModeNone,
+			Port:     port,
+			Server:   config.ServerConfig{DrainDelay: 200 * time.Millisecond, ShutdownTimeout: time.Second},
+		})
+	}()
+	status := func(path string) int {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Eventually(t, func() bool { return status("/readyz") == http.StatusOK }, 5*time.Second, 10*time.Millisecond)
+
+	// readiness fails while requests are still served during the drain delay
+	cancel()
+	assert.Eventually(t, func() bool { return a.shuttingDown.Load() }, time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, status("/readyz"))
+	assert.Equal(t, http.StatusOK, status("/healthz"))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	assert.Equal(t, 0, status("/healthz"))
+}
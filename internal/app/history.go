@@ -0,0 +1,264 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// FieldChange is a field that differs from the previous revision.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type RevisionResponse struct {
+	store.LinkRevision
+	Changes []FieldChange `json:"changes"`
+}
+
+type HistoryResponse struct {
+	Link string `json:"link"`
+	// Revisions are newest first.
+	Revisions []RevisionResponse `json:"revisions"`
+}
+
+// RollbackInput is the payload for POST /api/links/{name}/rollback.
+type RollbackInput struct {
+	Revision int `json:"revision"`
+}
+
+// baselineRevision is the first revision of a link changed before revisions
+// were recorded.
+func baselineRevision(link store.Link) store.LinkRevision {
+	return store.LinkRevision{
+		Link:        link.Name,
+		Number:      1,
+		URL:         link.URL,
+		Description: link.Description,
+		Author:      link.CreatedBy,
+		Created:     link.Updated,
+	}
+}
+
+// diffRevisions returns the fields changed from previous to revision.
+func diffRevisions(previous store.LinkRevision, revision store.LinkRevision) []FieldChange {
+	changes := []FieldChange{}
+	if previous.URL != revision.URL {
+		changes = append(changes, FieldChange{Field: "url", From: previous.URL, To: revision.URL})
+	}
+	if previous.Description != revision.Description {
+		changes = append(changes, FieldChange{Field: "description", From: previous.Description, To: revision.Description})
+	}
+	return changes
+}
+
+// recordRevision stores the URL and description of after as a new revision
+// if they changed from before, which is nil for new links. Failures are
+// logged and never fail the change itself.
+func (a *App) recordRevision(r *http.Request, before *store.Link, after store.Link) {
+	if _, err := a.addRevision(r, before, after, 0); err != nil {
+		a.Logger.With("link", after.Name).Error(err.Error())
+	}
+}
+
+// addRevision stores after as a new revision if it differs from before or
+// rolls back to revision rolledBackFrom. A link changed for the first time
+// since revisions were recorded gets its baseline revision first.
+func (a *App) addRevision(r *http.Request, before *store.Link, after store.Link, rolledBackFrom int) (store.LinkRevision, error) {
+	if a.Revisions == nil {
+		return store.LinkRevision{}, nil
+	}
+	if before != nil && rolledBackFrom == 0 && before.URL == after.URL && before.Description == after.Description {
+		return store.LinkRevision{}, nil
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		return store.LinkRevision{}, err
+	}
+	if before != nil {
+		revisions, err := a.Revisions.GetRevisions(r.Context(), before.Name)
+		if err != nil {
+			return store.LinkRevision{}, err
+		}
+		if len(revisions) == 0 {
+			_, err = a.Revisions.AddRevision(r.Context(), baselineRevision(*before))
+			if err != nil {
+				return store.LinkRevision{}, err
+			}
+		}
+	}
+	return a.Revisions.AddRevision(r.Context(), store.LinkRevision{
+		Link:           after.Name,
+		URL:            after.URL,
+		Description:    after.Description,
+		Author:         identity.Email,
+		Created:        time.Now().UTC(),
+		RolledBackFrom: rolledBackFrom,
+	})
+}
+
+// linkRevisions returns the revisions of link, oldest first, including the
+// baseline of a link that has not changed since revisions were recorded.
+func (a *App) linkRevisions(r *http.Request, link store.Link) ([]store.LinkRevision, error) {
+	revisions, err := a.Revisions.GetRevisions(r.Context(), link.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		revisions = append(revisions, baselineRevision(link))
+	}
+	return revisions, nil
+}
+
+// handleLinkHistory serves the revisions of a link, newest first, at
+// /api/links/{name}/history.
+func (a *App) handleLinkHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	if a.Revisions == nil {
+		sendError(w, http.StatusNotImplemented, ErrorResponse{Error: "link history is not supported by this store"})
+		return
+	}
+	name, err := cleanLink(strings.TrimSuffix(strings.ToLower(r.URL.Path)[len("/api/links/"):], "/history"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	link, err := a.Store.GetLinkByName(r.Context(), name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	revisions, err := a.linkRevisions(r, link)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	resp := HistoryResponse{Link: link.Name, Revisions: make([]RevisionResponse, 0, len(revisions))}
+	previous := store.LinkRevision{}
+	for _, revision := range revisions {
+		resp.Revisions = append(resp.Revisions, RevisionResponse{LinkRevision: revision, Changes: diffRevisions(previous, revision)})
+		previous = revision
+	}
+	for i, j := 0, len(resp.Revisions)-1; i < j; i, j = i+1, j-1 {
+		resp.Revisions[i], resp.Revisions[j] = resp.Revisions[j], resp.Revisions[i]
+	}
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+}
+
+// handleRollbackLink restores the URL and description of an earlier revision
+// as a new revision at /api/links/{name}/rollback.
+func (a *App) handleRollbackLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	if a.Revisions == nil {
+		sendError(w, http.StatusNotImplemented, ErrorResponse{Error: "link history is not supported by this store"})
+		return
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	name, err := cleanLink(strings.TrimSuffix(strings.ToLower(r.URL.Path)[len("/api/links/"):], "/rollback"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	input := RollbackInput{}
+	if err := json.Unmarshal(body, &input); err != nil || input.Revision < 1 {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid payload"})
+		return
+	}
+
+	existing, err := a.Store.GetLinkByName(r.Context(), name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	if !a.canModifyLink(identity, existing) {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "only the link owner can roll back this link"})
+		return
+	}
+	revisions, err := a.linkRevisions(r, existing)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	var target *store.LinkRevision
+	for _, revision := range revisions {
+		if revision.Number == input.Revision {
+			found := revision
+			target = &found
+			break
+		}
+	}
+	if target == nil {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "revision not found"})
+		return
+	}
+
+	err = a.Store.UpdateLink(r.Context(), existing.Name, store.LinkPatch{URL: &target.URL, Description: &target.Description})
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	rolledBack := existing
+	rolledBack.URL = target.URL
+	rolledBack.Description = target.Description
+	updated := a.currentLink(r.Context(), existing.Name, rolledBack)
+	a.recordAudit(r, store.AuditActionUpdate, existing.Name, &existing, updated)
+	revision, err := a.addRevision(r, &existing, *updated, target.Number)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	err = json.NewEncoder(w).Encode(revision)
+	if err != nil {
+		a.Logger.Error(err.Error())
+	}
+}
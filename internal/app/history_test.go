@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkHistory(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{TrustedCIDRs: []string{"10.0.0.1"}, EmailHeader: "X-Forwarded-Email"}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := store.NewMemoryStore()
+	a := App{Store: s, Revisions: s, Logger: logger, proxy: auth, config: &config.Config{}}
+	router := mux.NewRouter()
+	router.PathPrefix("/api/").Handler(a.authWrapper(http.HandlerFunc(a.handleApi)))
+	router.Path("/{link:.*}").Handler(a.authWrapper(http.HandlerFunc(a.handleLink)))
+	serve := func(method string, target string, body string, email string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-Email", email)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	history := func(name string) HistoryResponse {
+		w := serve(http.MethodGet, "/api/links/"+name+"/history", "", "other@example.com")
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		resp := HistoryResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+	numbers := func(resp HistoryResponse) []int {
+		numbers := []int{}
+		for _, revision := range resp.Revisions {
+			numbers = append(numbers, revision.Number)
+		}
+		return numbers
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/oncall", `{"url": "https://example.com/oncall", "description": "On call"}`, "owner@example.com").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPatch, "/oncall", `{"url": "https://example.com/wrong"}`, "owner@example.com").Code)
+	// changes that leave the URL and description alone are not revisions
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPatch, "/oncall", `{"url": "https://example.com/wrong"}`, "owner@example.com").Code)
+
+	resp := history("OnCall")
+	assert.Equal(t, "oncall", resp.Link)
+	if assert.Equal(t, []int{2, 1}, numbers(resp)) {
+		assert.Equal(t, []FieldChange{{Field: "url", From: "https://example.com/oncall", To: "https://example.com/wrong"}}, resp.Revisions[0].Changes)
+		assert.Equal(t, "owner@example.com", resp.Revisions[0].Author)
+		assert.Len(t, resp.Revisions[1].Changes, 2)
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/links/oncall/rollback", `{"revision": 1}`, "other@example.com").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/api/links/oncall/rollback", `{"revision": 7}`, "owner@example.com").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/links/oncall/rollback", `{}`, "owner@example.com").Code)
+	w := serve(http.MethodPost, "/api/links/oncall/rollback", `{"revision": 1}`, "owner@example.com")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		revision := store.LinkRevision{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&revision))
+		assert.Equal(t, 3, revision.Number)
+		assert.Equal(t, 1, revision.RolledBackFrom)
+	}
+	link, _ := s.GetLinkByName(ctx, "oncall")
+	assert.Equal(t, "https://example.com/oncall", link.URL)
+	resp = history("oncall")
+	if assert.Equal(t, []int{3, 2, 1}, numbers(resp)) {
+		assert.Equal(t, []FieldChange{{Field: "url", From: "https://example.com/wrong", To: "https://example.com/oncall"}}, resp.Revisions[0].Changes)
+	}
+
+	// links from before revisions were recorded start from their current state
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs", CreatedBy: "owner@example.com"}))
+	resp = history("docs")
+	if assert.Equal(t, []int{1}, numbers(resp)) {
+		assert.Equal(t, "owner@example.com", resp.Revisions[0].Author)
+	}
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPatch, "/docs", `{"url": "https://example.com/new"}`, "owner@example.com").Code)
+	assert.Equal(t, []int{2, 1}, numbers(history("docs")))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/links/docs/rollback", `{"revision": 1}`, "owner@example.com").Code)
+	link, _ = s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, "https://example.com/docs", link.URL)
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api/links/missing/history", "", "owner@example.com").Code)
+	a.Revisions = nil
+	assert.Equal(t, http.StatusNotImplemented, serve(http.MethodGet, "/api/links/oncall/history", "", "owner@example.com").Code)
+}
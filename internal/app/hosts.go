@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/imdevinc/go-links/internal/config"
+)
+
+// hosts decides which request hosts are served directly and which namespace
+// their links are looked up in.
+type hosts struct {
+	fqdn       string
+	redirect   config.HostRedirect
+	accepted   map[string]bool
+	namespaces map[string]string
+}
+
+func newHosts(fqdn string, cfg config.HostsConfig) (*hosts, error) {
+	h := &hosts{
+		fqdn:       fqdn,
+		redirect:   cfg.Redirect,
+		accepted:   map[string]bool{normalizeHost(fqdn): true},
+		namespaces: map[string]string{},
+	}
+	switch cfg.Redirect {
+	case "", config.HostRedirectPermanent, config.HostRedirectTemporary, config.HostRedirectNone:
+	default:
+		return nil, fmt.Errorf("unknown host redirect %q", cfg.Redirect)
+	}
+	for _, host := range cfg.Accepted {
+		h.accepted[normalizeHost(host)] = true
+	}
+	for host, namespace := range cfg.Namespaces {
+		namespace, err := cleanLink(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace for host %q: %w", host, err)
+		}
+		h.accepted[normalizeHost(host)] = true
+		h.namespaces[normalizeHost(host)] = namespace
+	}
+	return h, nil
+}
+
+// normalizeHost lowercases host and removes its port, the brackets around an
+// IPv6 literal and any trailing dot, so hosts can be compared.
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// accepts reports whether requests for host are served without redirecting.
+func (h *hosts) accepts(host string) bool {
+	return h.redirect == config.HostRedirectNone || h.accepted[normalizeHost(host)]
+}
+
+// namespace returns the namespace links are looked up in first for host,
+// or "" when host has none or hosts are not configured.
+func (h *hosts) namespace(host string) string {
+	if h == nil {
+		return ""
+	}
+	return h.namespaces[normalizeHost(host)]
+}
+
+// redirectCode is the status to redirect a request with method to FQDN with.
+// Methods other than GET and HEAD keep their method and body.
+func (h *hosts) redirectCode(method string) int {
+	keepMethod := method != http.MethodGet && method != http.MethodHead
+	switch {
+	case h.redirect == config.HostRedirectTemporary && keepMethod:
+		return http.StatusTemporaryRedirect
+	case h.redirect == config.HostRedirectTemporary:
+		return http.StatusFound
+	case keepMethod:
+		return http.StatusPermanentRedirect
+	}
+	return http.StatusMovedPermanently
+}
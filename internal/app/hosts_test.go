@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	cases := map[string]string{
+		"go.example.com":      "go.example.com",
+		"Go.Example.com.":     "go.example.com",
+		"go.example.com:8080": "go.example.com",
+		"[fd00::1]":           "fd00::1",
+		"[FD00::1]:8080":      "fd00::1",
+		"fd00::1":             "fd00::1",
+		"10.0.0.1:80":         "10.0.0.1",
+	}
+	for host, expected := range cases {
+		assert.Equal(t, expected, normalizeHost(host), host)
+	}
+}
+
+func TestIndexHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		redirect config.HostRedirect
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{name: "fqdn", target: "http://go.example.com/docs", code: http.StatusOK},
+		{name: "fqdn with port", target: "http://go.example.com:8080/docs", code: http.StatusOK},
+		{name: "accepted host", target: "http://GO.CORP/docs", code: http.StatusOK},
+		{name: "accepted ipv6 literal", target: "http://[fd00::1]:8080/docs", code: http.StatusOK},
+		{name: "namespaced host", target: "http://go.internal/docs", code: http.StatusOK},
+		{
+			name:     "short host keeps query",
+			target:   "http://go/docs/setup?q=links&page=2",
+			code:     http.StatusMovedPermanently,
+			location: "//go.example.com/docs/setup?q=links&page=2",
+		},
+		{
+			name:     "post keeps method",
+			method:   http.MethodPost,
+			target:   "http://go/api/links",
+			code:     http.StatusPermanentRedirect,
+			location: "//go.example.com/api/links",
+		},
+		{
+			name:     "temporary",
+			redirect: config.HostRedirectTemporary,
+			target:   "http://go/docs",
+			code:     http.StatusFound,
+			location: "//go.example.com/docs",
+		},
+		{
+			name:     "temporary post keeps method",
+			redirect: config.HostRedirectTemporary,
+			method:   http.MethodPost,
+			target:   "http://go/api/links",
+			code:     http.StatusTemporaryRedirect,
+			location: "//go.example.com/api/links",
+		},
+		{name: "none", redirect: config.HostRedirectNone, target: "http://go/docs", code: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			redirect := tc.redirect
+			if redirect == "" {
+				redirect = config.HostRedirectPermanent
+			}
+			hosts, err := newHosts("go.example.com", config.HostsConfig{
+				Accepted:   []string{"go.corp", "fd00::1"},
+				Redirect:   redirect,
+				Namespaces: map[string]string{"go.internal": "internal"},
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			a := App{config: &config.Config{FQDN: "go.example.com"}, hosts: hosts}
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := httptest.NewRecorder()
+			a.indexHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest(method, tc.target, nil))
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestNewHosts(t *testing.T) {
+	_, err := newHosts("go.example.com", config.HostsConfig{Redirect: "sometimes"})
+	assert.EqualError(t, err, `unknown host redirect "sometimes"`)
+	_, err = newHosts("go.example.com", config.HostsConfig{Namespaces: map[string]string{"go.corp": "-corp"}})
+	assert.EqualError(t, err, `invalid namespace for host "go.corp": name input is invalid`)
+}
+
+func TestHostNamespaces(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	for _, link := range []store.Link{
+		{Name: "docs", URL: "https://docs.example.com"},
+		{Name: "wiki", URL: "https://wiki.example.com"},
+		{Name: "internal", URL: "https://internal.example.com"},
+		{Name: "internal/docs", URL: "https://docs.internal.example.com"},
+	} {
+		assert.NoError(t, s.CreateLink(ctx, link))
+	}
+	hosts, err := newHosts("go.example.com", config.HostsConfig{
+		Redirect:   config.HostRedirectPermanent,
+		Namespaces: map[string]string{"go.internal": "Internal"},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a := App{Store: s, Logger: slog.Default(), config: &config.Config{FQDN: "go.example.com"}, hosts: hosts}
+
+	cases := []struct {
+		target   string
+		location string
+	}{
+		// the namespace's link is preferred on its host
+		{"http://go.internal/docs/setup", "https://docs.internal.example.com/setup"},
+		{"http://go.example.com/docs/setup", "https://docs.example.com/setup"},
+		// other links fall back to the ones outside the namespace
+		{"http://go.internal/wiki", "https://wiki.example.com"},
+		// the walk stops before the namespace link itself
+		{"http://go.internal/missing", "//go.example.com"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		r = mux.SetURLVars(r, map[string]string{"link": r.URL.Path})
+		w := httptest.NewRecorder()
+		a.handleLink(w, r)
+		assert.Equal(t, tc.location, w.Header().Get("Location"), tc.target)
+	}
+}
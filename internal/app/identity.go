@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/crewjam/saml/samlsp"
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// Identity is the authenticated user making a request.
+type Identity struct {
+	Email       string   `json:"email"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+}
+
+type identityKey struct{}
+
+// owners returns the link owner entries that match the identity.
+func (i Identity) owners() []string {
+	return store.OwnerPrincipals(i.Email, i.Groups)
+}
+
+// IdentityFromContext returns the Identity attached to ctx by the auth middleware.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+func contextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// withIdentity decodes the SAML session cookie, verifying its signature,
+// audience, issuer and expiry, and attaches the resulting Identity to the
+// request context. Requests without a valid session pass through untouched.
+func (a *App) withIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.sp != nil {
+			session, err := a.sp.Session.GetSession(r)
+			if err == nil {
+				if identity, ok := a.identityFromSession(session); ok {
+					r = r.WithContext(contextWithIdentity(r.Context(), identity))
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) identityFromSession(session samlsp.Session) (Identity, bool) {
+	claims, ok := session.(samlsp.JWTSessionClaims)
+	if !ok || claims.Subject == "" {
+		return Identity{}, false
+	}
+	return Identity{
+		Email:       claims.Subject,
+		DisplayName: claims.Attributes.Get(a.config.SSO.NameAttribute),
+		Groups:      claims.Attributes[a.config.SSO.GroupsAttribute],
+	}, true
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml/samlsp"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEmailFromRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	root, _ := url.Parse("https://go.example.com")
+	codec := samlsp.DefaultSessionCodec(samlsp.Options{URL: *root, Key: key})
+	codec.MaxAge = time.Hour
+	a := App{
+		config: &config.Config{SSO: config.SSOConfig{NameAttribute: "displayName", GroupsAttribute: "groups"}},
+		sp:     &samlsp.Middleware{Session: samlsp.CookieSessionProvider{Name: "token", Codec: codec}},
+	}
+	claims := func(audience string, expires time.Time) samlsp.JWTSessionClaims {
+		claims := samlsp.JWTSessionClaims{
+			Attributes: samlsp.Attributes{
+				"displayName": {"Test User"},
+				"groups":      {"engineering", "admins"},
+			},
+			SAMLSession: true,
+		}
+		claims.Subject = "user@example.com"
+		claims.Audience = audience
+		claims.Issuer = "https://go.example.com"
+		claims.ExpiresAt = expires.Unix()
+		return claims
+	}
+	forgedCodec := codec
+	forgedCodec.Key = otherKey
+
+	cases := []struct {
+		Name          string
+		Codec         samlsp.JWTSessionCodec
+		Claims        samlsp.JWTSessionClaims
+		ExpectedError bool
+	}{
+		{
+			Name:   "Valid session",
+			Codec:  codec,
+			Claims: claims("https://go.example.com", time.Now().Add(time.Hour)),
+		},
+		{
+			Name:          "Forged signature",
+			Codec:         forgedCodec,
+			Claims:        claims("https://go.example.com", time.Now().Add(time.Hour)),
+			ExpectedError: true,
+		},
+		{
+			Name:          "Expired session",
+			Codec:         codec,
+			Claims:        claims("https://go.example.com", time.Now().Add(-time.Hour)),
+			ExpectedError: true,
+		},
+		{
+			Name:          "Wrong audience",
+			Codec:         codec,
+			Claims:        claims("https://evil.example.com", time.Now().Add(time.Hour)),
+			ExpectedError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			token, err := tc.Codec.Encode(tc.Claims)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			r := httptest.NewRequest(http.MethodGet, "/api/owned", nil)
+			r.AddCookie(&http.Cookie{Name: "token", Value: token})
+			var email string
+			var identity Identity
+			var emailErr error
+			a.withIdentity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				email, emailErr = a.getEmailFromRequest(r)
+				identity, _ = IdentityFromContext(r.Context())
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			if tc.ExpectedError {
+				assert.Error(t, emailErr)
+				return
+			}
+			if !assert.NoError(t, emailErr) {
+				t.FailNow()
+			}
+			assert.Equal(t, "user@example.com", email)
+			assert.Equal(t, Identity{
+				Email:       "user@example.com",
+				DisplayName: "Test User",
+				Groups:      []string{"engineering", "admins"},
+			}, identity)
+		})
+	}
+
+	t.Run("Missing cookie", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/owned", nil)
+		a.withIdentity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := a.getEmailFromRequest(r)
+			assert.Error(t, err)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+	})
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// linkCountsTTL is how long link counts are reused between scrapes, since
+// counting walks every link in the store.
+const linkCountsTTL = time.Minute
+
+// apiRoutes are the API paths reported as their own route. Paths with a link
+// name or token ID are reported with a placeholder instead.
+var apiRoutes = []string{
+	"/api/audit",
+	"/api/cache",
+	"/api/export",
+	"/api/import",
+	"/api/owned",
+	"/api/popular",
+	"/api/query",
+	"/api/recent",
+	"/api/tokens",
+	"/api/trash",
+}
+
+var linkRouteSuffixes = []string{"history", "owners", "rollback", "stats", "transfer"}
+
+type appMetrics struct {
+	requests  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	redirects *prometheus.CounterVec
+}
+
+func newAppMetrics(registerer prometheus.Registerer, s store.Store, logger *slog.Logger) (*appMetrics, error) {
+	m := &appMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "golinks",
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "golinks",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "golinks",
+			Name:      "redirects_total",
+			Help:      "Link redirects by result, hit when the link exists and miss when it does not.",
+		}, []string{"result"}),
+	}
+	collectors := []prometheus.Collector{m.requests, m.duration, m.redirects, newLinkCounts(s, logger)}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// instrument records the count and latency of requests to next.
+func (m *appMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"route":  metricsRoute(r.URL.Path),
+			"method": metricsMethod(r.Method),
+			"code":   strconv.Itoa(recorder.status),
+		}
+		m.requests.With(labels).Inc()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// redirect counts a redirect hit or miss. It does nothing when metrics are
+// disabled.
+func (m *appMetrics) redirect(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.redirects.WithLabelValues(result).Inc()
+}
+
+// metricsRoute maps a request path to a route label, replacing link names and
+// IDs so the number of routes stays small.
+func metricsRoute(path string) string {
+	path = strings.ToLower(path)
+	switch {
+	case path == "/":
+		return "/"
+	case strings.HasPrefix(path, "/saml/"):
+		return "/saml"
+	case strings.HasPrefix(path, "/oidc/"):
+		return "/oidc"
+	case strings.HasPrefix(path, "/static/") || slices.Contains(staticAssets, path):
+		return "/static"
+	case slices.Contains(apiRoutes, path):
+		return path
+	case strings.HasPrefix(path, "/api/trash/"):
+		return "/api/trash/{name}"
+	case strings.HasPrefix(path, "/api/tokens/"):
+		return "/api/tokens/{id}"
+	case strings.HasPrefix(path, "/api/links/"):
+		if i := strings.LastIndex(path, "/"); slices.Contains(linkRouteSuffixes, path[i+1:]) {
+			return "/api/links/{name}/" + path[i+1:]
+		}
+		return "/api/other"
+	case strings.HasPrefix(path, "/api"):
+		return "/api/other"
+	}
+	return "/{link}"
+}
+
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+// statusRecorder remembers the status code written to a ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// linkCounts exports the number of active and disabled links.
+type linkCounts struct {
+	store  store.Store
+	logger *slog.Logger
+	desc   *prometheus.Desc
+	mu     sync.Mutex
+	active int
+	// disabled is -1 until the links have been counted once.
+	disabled int
+	updated  time.Time
+}
+
+func newLinkCounts(s store.Store, logger *slog.Logger) *linkCounts {
+	return &linkCounts{
+		store:    s,
+		logger:   logger,
+		desc:     prometheus.NewDesc("golinks_links", "Links in the store by state, active or disabled.", []string{"state"}, nil),
+		disabled: -1,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (l *linkCounts) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.desc
+}
+
+// Collect implements prometheus.Collector. If the links can not be counted
+// the previous counts are reported.
+func (l *linkCounts) Collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.updated) >= linkCountsTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		active, disabled := 0, 0
+		err := l.store.WalkLinks(ctx, func(link store.Link) error {
+			if link.Disabled {
+				disabled++
+			} else {
+				active++
+			}
+			return nil
+		})
+		if err != nil {
+			l.logger.With("error", err).Warn("failed to count links")
+		} else {
+			l.active, l.disabled, l.updated = active, disabled, time.Now()
+		}
+	}
+	if l.disabled < 0 {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(l.desc, prometheus.GaugeValue, float64(l.active), "active")
+	ch <- prometheus.MustNewConstMetric(l.desc, prometheus.GaugeValue, float64(l.disabled), "disabled")
+}
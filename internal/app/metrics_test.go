@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	cases := map[string]string{
+		"/":                          "/",
+		"/docs":                      "/{link}",
+		"/docs/api/v2":               "/{link}",
+		"/favicon.ico":               "/static",
+		"/static/js/main.js":         "/static",
+		"/saml/acs":                  "/saml",
+		"/API/Popular":               "/api/popular",
+		"/api/trash/docs":            "/api/trash/{name}",
+		"/api/tokens/abc123":         "/api/tokens/{id}",
+		"/api/links/docs/api/stats":  "/api/links/{name}/stats",
+		"/api/links/docs/history":    "/api/links/{name}/history",
+		"/api/links/docs/everything": "/api/other",
+		"/api/unknown":               "/api/other",
+	}
+	for path, expected := range cases {
+		assert.Equal(t, expected, metricsRoute(path), path)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com"}))
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "old", URL: "https://example.com/old"}))
+	assert.NoError(t, s.DisableLink(ctx, "old"))
+
+	registry := prometheus.NewRegistry()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	metrics, err := newAppMetrics(registry, s, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a := App{Store: s, Logger: logger, config: &config.Config{FQDN: "go"}, metrics: metrics}
+	router := mux.NewRouter()
+	router.Path("/{link:.*}").Handler(a.authWrapper(http.HandlerFunc(a.handleLink)))
+	handler := metrics.instrument(router)
+	for _, path := range []string{"/docs", "/docs", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/{link}", http.MethodGet, "302")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/{link}", http.MethodGet, "307")))
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP golinks_redirects_total Link redirects by result, hit when the link exists and miss when it does not.
+# TYPE golinks_redirects_total counter
+golinks_redirects_total{result="hit"} 2
+golinks_redirects_total{result="miss"} 1
+# HELP golinks_links Links in the store by state, active or disabled.
+# TYPE golinks_links gauge
+golinks_links{state="active"} 1
+golinks_links{state="disabled"} 1
+`), "golinks_redirects_total", "golinks_links"))
+}
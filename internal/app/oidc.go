@@ -0,0 +1,288 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/imdevinc/go-links/internal/config"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcSessionCookie = "oidc_session"
+	oidcFlowCookie    = "oidc_flow"
+	// oidcFlowMaxAge is how long a user has to log in at the identity provider.
+	oidcFlowMaxAge = 10 * time.Minute
+)
+
+// oidcAuth authenticates users with the OpenID Connect authorization code
+// flow with PKCE, keeping the resulting identity in a signed session cookie.
+type oidcAuth struct {
+	cfg        config.OIDCConfig
+	oauth2     oauth2.Config
+	verifier   *oidc.IDTokenVerifier
+	sessionKey []byte
+	secure     bool
+	logger     *slog.Logger
+}
+
+type oidcSessionClaims struct {
+	jwt.RegisteredClaims
+	Name   string   `json:"name,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// oidcFlowClaims carry the state of a login between the redirect to the
+// identity provider and the callback.
+type oidcFlowClaims struct {
+	jwt.RegisteredClaims
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+}
+
+func newOIDCAuth(ctx context.Context, cfg config.OIDCConfig, fqdn string, logger *slog.Logger) (*oidcAuth, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover issuer: %w", err)
+	}
+	redirectURL := cfg.RedirectURL
+	if redirectURL == "" {
+		redirectURL = fmt.Sprintf("https://%s/oidc/callback", fqdn)
+	}
+	sessionKey := cfg.SessionKey
+	if len(sessionKey) == 0 {
+		logger.Warn("OIDC_SESSION_KEY is not set, sessions will not survive restarts or work across replicas")
+		sessionKey = make([]byte, 32)
+		if _, err := rand.Read(sessionKey); err != nil {
+			return nil, err
+		}
+	}
+	return &oidcAuth{
+		cfg: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       cfg.Scopes,
+		},
+		verifier:   provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		sessionKey: sessionKey,
+		secure:     strings.HasPrefix(redirectURL, "https://"),
+		logger:     logger,
+	}, nil
+}
+
+// ServeHTTP serves the login flow under /oidc/.
+func (o *oidcAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/oidc/login":
+		o.handleLogin(w, r)
+	case "/oidc/callback":
+		o.handleCallback(w, r)
+	case "/oidc/logout":
+		o.setCookie(w, oidcSessionCookie, "", -1)
+		http.Redirect(w, r, "/", http.StatusFound)
+	default:
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+	}
+}
+
+// RequireAccount attaches the session's Identity to the request context.
+// Requests without a valid session are sent to log in, except API requests,
+// which are rejected.
+func (o *oidcAuth) RequireAccount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := o.identify(r)
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(contextWithIdentity(r.Context(), identity)))
+			return
+		}
+		if r.Method != http.MethodGet || strings.HasPrefix(r.URL.Path, "/api") {
+			sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+			return
+		}
+		http.Redirect(w, r, "/oidc/login?return_to="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+	})
+}
+
+func (o *oidcAuth) identify(r *http.Request) (Identity, error) {
+	cookie, err := r.Cookie(oidcSessionCookie)
+	if err != nil {
+		return Identity{}, err
+	}
+	claims := oidcSessionClaims{}
+	if err := o.parse(cookie.Value, &claims); err != nil {
+		return Identity{}, err
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("session has no subject")
+	}
+	return Identity{Email: claims.Subject, DisplayName: claims.Name, Groups: claims.Groups}, nil
+}
+
+func (o *oidcAuth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	returnTo := r.URL.Query().Get("return_to")
+	// only local paths are allowed so the login can not be used as an open redirect
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		returnTo = "/"
+	}
+	flow := oidcFlowClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcFlowMaxAge))},
+		State:            randomString(),
+		Nonce:            randomString(),
+		Verifier:         oauth2.GenerateVerifier(),
+		ReturnTo:         returnTo,
+	}
+	token, err := o.sign(flow)
+	if err != nil {
+		o.logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	o.setCookie(w, oidcFlowCookie, token, int(oidcFlowMaxAge.Seconds()))
+	http.Redirect(w, r, o.oauth2.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier)), http.StatusFound)
+}
+
+func (o *oidcAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "login expired, please try again"})
+		return
+	}
+	flow := oidcFlowClaims{}
+	if err := o.parse(cookie.Value, &flow); err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "login expired, please try again"})
+		return
+	}
+	o.setCookie(w, oidcFlowCookie, "", -1)
+	query := r.URL.Query()
+	if query.Get("state") != flow.State {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid login state"})
+		return
+	}
+	if errorCode := query.Get("error"); errorCode != "" {
+		o.logger.With("error", errorCode, "description", query.Get("error_description")).Warn("identity provider rejected login")
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "login failed"})
+		return
+	}
+
+	token, err := o.oauth2.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		o.logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "login failed"})
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		o.logger.Error("token response has no id_token")
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "login failed"})
+		return
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		o.logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "login failed"})
+		return
+	}
+	if idToken.Nonce != flow.Nonce {
+		o.logger.Error("id token nonce does not match")
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "login failed"})
+		return
+	}
+	identity, err := o.identityFromToken(idToken)
+	if err != nil {
+		o.logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "login failed"})
+		return
+	}
+
+	session, err := o.sign(oidcSessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   identity.Email,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(o.cfg.SessionMaxAge)),
+		},
+		Name:   identity.DisplayName,
+		Groups: identity.Groups,
+	})
+	if err != nil {
+		o.logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	o.setCookie(w, oidcSessionCookie, session, int(o.cfg.SessionMaxAge.Seconds()))
+	http.Redirect(w, r, flow.ReturnTo, http.StatusFound)
+}
+
+func (o *oidcAuth) identityFromToken(idToken *oidc.IDToken) (Identity, error) {
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, err
+	}
+	email, _ := claims[o.cfg.EmailClaim].(string)
+	if email == "" {
+		return Identity{}, fmt.Errorf("id token has no %s claim", o.cfg.EmailClaim)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified && o.cfg.EmailClaim == "email" {
+		return Identity{}, fmt.Errorf("email %s is not verified", email)
+	}
+	identity := Identity{Email: email}
+	identity.DisplayName, _ = claims[o.cfg.NameClaim].(string)
+	switch groups := claims[o.cfg.GroupsClaim].(type) {
+	case []any:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+	return identity, nil
+}
+
+func (o *oidcAuth) sign(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(o.sessionKey)
+}
+
+func (o *oidcAuth) parse(token string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+		}
+		return o.sessionKey, nil
+	})
+	return err
+}
+
+func (o *oidcAuth) setCookie(w http.ResponseWriter, name string, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// testIssuer is a minimal OpenID Connect provider that logs in a fixed user
+// without prompting.
+type testIssuer struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	issuer := &testIssuer{key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "pkce required", http.StatusBadRequest)
+			return
+		}
+		code := randomString()
+		issuer.mu.Lock()
+		issuer.codes[code] = query
+		issuer.mu.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		issuer.mu.Lock()
+		authorize, ok := issuer.codes[r.Form.Get("code")]
+		delete(issuer.codes, r.Form.Get("code"))
+		issuer.mu.Unlock()
+		challenge := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != authorize.Get("code_challenge") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            issuer.URL,
+			"sub":            "1234",
+			"aud":            authorize.Get("client_id"),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          authorize.Get("nonce"),
+			"email":          "user@example.com",
+			"email_verified": true,
+			"name":           "Test User",
+			"groups":         []string{"engineering", "admins"},
+		})
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func TestOIDCLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newOIDCAuth(context.Background(), config.OIDCConfig{
+		Issuer:        issuer.URL,
+		ClientID:      "go-links",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://go.example.com/oidc/callback",
+		Scopes:        []string{"openid", "email", "profile"},
+		EmailClaim:    "email",
+		NameClaim:     "name",
+		GroupsClaim:   "groups",
+		SessionKey:    []byte("test session key"),
+		SessionMaxAge: time.Hour,
+	}, "go.example.com", logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a := App{Logger: logger, oidc: auth}
+
+	var identity Identity
+	handler := http.NewServeMux()
+	handler.Handle("/oidc/", auth)
+	handler.Handle("/", auth.RequireAccount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, err := a.getEmailFromRequest(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "user@example.com", email)
+		identity, _ = IdentityFromContext(r.Context())
+	})))
+	serve := func(target string, cookies ...*http.Cookie) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+	cookie := func(resp *http.Response, name string) *http.Cookie {
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == name {
+				return cookie
+			}
+		}
+		t.Fatalf("missing %s cookie", name)
+		return nil
+	}
+
+	resp := serve("/api/owned")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = serve("/docs?q=1")
+	if !assert.Equal(t, http.StatusFound, resp.StatusCode) {
+		t.FailNow()
+	}
+	resp = serve(resp.Header.Get("Location"))
+	if !assert.Equal(t, http.StatusFound, resp.StatusCode) {
+		t.FailNow()
+	}
+	flow := cookie(resp, oidcFlowCookie)
+
+	// the issuer redirects straight back to the callback
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	authorize, err := client.Get(resp.Header.Get("Location"))
+	if !assert.NoError(t, err) || !assert.Equal(t, http.StatusFound, authorize.StatusCode) {
+		t.FailNow()
+	}
+	callback, err := url.Parse(authorize.Header.Get("Location"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	tampered := callback.Query()
+	tampered.Set("state", "forged")
+	resp = serve("/oidc/callback?"+tampered.Encode(), flow)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = serve(callback.RequestURI(), flow)
+	if !assert.Equal(t, http.StatusFound, resp.StatusCode) {
+		t.FailNow()
+	}
+	assert.Equal(t, "/docs?q=1", resp.Header.Get("Location"))
+	session := cookie(resp, oidcSessionCookie)
+	assert.True(t, session.HttpOnly)
+
+	resp = serve("/docs", session)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, Identity{Email: "user@example.com", DisplayName: "Test User", Groups: []string{"engineering", "admins"}}, identity)
+
+	// the code can only be exchanged once
+	resp = serve(callback.RequestURI(), flow)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, oidcSessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte("another key"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	resp = serve("/api/owned", &http.Cookie{Name: oidcSessionCookie, Value: forged})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = serve("/oidc/login?return_to=//evil.example.com")
+	flowClaims := oidcFlowClaims{}
+	if assert.NoError(t, auth.parse(cookie(resp, oidcFlowCookie).Value, &flowClaims)) {
+		assert.Equal(t, "/", flowClaims.ReturnTo)
+	}
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// OwnerInput is the payload for the ownership endpoints. Groups are given
+// as "group:name".
+type OwnerInput struct {
+	Owner string `json:"owner"`
+}
+
+type OwnersResponse struct {
+	Owners []string `json:"owners"`
+}
+
+var errOwnerNotFound = errors.New("owner not found")
+var errLastOwner = errors.New("a link must keep at least one owner")
+
+// handleLinkOwners adds (POST) or removes (DELETE) an owner of a link at
+// /api/links/{name}/owners.
+func (a *App) handleLinkOwners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	a.changeOwners(w, r, "/owners", func(owners []string, owner string) ([]string, error) {
+		index := slices.IndexFunc(owners, func(existing string) bool {
+			return strings.EqualFold(existing, owner)
+		})
+		if r.Method == http.MethodPost {
+			if index >= 0 {
+				return owners, nil
+			}
+			return append(owners, owner), nil
+		}
+		if index < 0 {
+			return nil, errOwnerNotFound
+		}
+		if len(owners) == 1 {
+			return nil, errLastOwner
+		}
+		return slices.Delete(owners, index, index+1), nil
+	})
+}
+
+// handleTransferLink makes the given user or group the only owner of a link
+// at /api/links/{name}/transfer.
+func (a *App) handleTransferLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	a.changeOwners(w, r, "/transfer", func(owners []string, owner string) ([]string, error) {
+		return []string{owner}, nil
+	})
+}
+
+// changeOwners replaces the owners of the link named in the request path with
+// the result of change, if the caller owns the link or is an admin.
+func (a *App) changeOwners(w http.ResponseWriter, r *http.Request, suffix string, change func(owners []string, owner string) ([]string, error)) {
+	w.Header().Set("Content-Type", "application/json")
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	name, err := cleanLink(strings.TrimSuffix(strings.ToLower(r.URL.Path)[len("/api/links/"):], suffix))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	input := OwnerInput{}
+	if err := json.Unmarshal(body, &input); err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid payload"})
+		return
+	}
+	owner := strings.TrimSpace(input.Owner)
+	if owner == "" || owner == store.GroupOwnerPrefix {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "owner is required"})
+		return
+	}
+
+	existing, err := a.Store.GetLinkByName(r.Context(), name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	if !a.canModifyLink(identity, existing) {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "only the link owner can change its owners"})
+		return
+	}
+	owners, err := change(slices.Clone(existing.Owners), owner)
+	if errors.Is(err, errOwnerNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	err = a.Store.UpdateLink(r.Context(), existing.Name, store.LinkPatch{Owners: owners})
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	changed := existing
+	changed.Owners = owners
+	a.recordAudit(r, store.AuditActionOwners, existing.Name, &existing, a.currentLink(r.Context(), existing.Name, changed))
+	err = json.NewEncoder(w).Encode(OwnersResponse{Owners: owners})
+	if err != nil {
+		a.Logger.Error(err.Error())
+	}
+}
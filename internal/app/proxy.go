@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/imdevinc/go-links/internal/config"
+)
+
+// proxyAuth trusts the identity headers set by an authenticating reverse
+// proxy, such as oauth2-proxy, for requests coming from that proxy.
+type proxyAuth struct {
+	cfg     config.ProxyConfig
+	trusted []netip.Prefix
+	logger  *slog.Logger
+}
+
+func newProxyAuth(cfg config.ProxyConfig, logger *slog.Logger) (*proxyAuth, error) {
+	if len(cfg.TrustedCIDRs) == 0 {
+		return nil, fmt.Errorf("PROXY_TRUSTED_CIDRS must be set")
+	}
+	p := &proxyAuth{cfg: cfg, logger: logger}
+	for _, cidr := range cfg.TrustedCIDRs {
+		cidr = strings.TrimSpace(cidr)
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.trusted = append(p.trusted, prefix.Masked())
+	}
+	return p, nil
+}
+
+// RequireAccount attaches the Identity from the proxy's headers to the request
+// context, rejecting requests that did not come through a trusted proxy.
+func (p *proxyAuth) RequireAccount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.isTrusted(r.RemoteAddr) {
+			p.logger.With("remote_addr", r.RemoteAddr).Warn("rejecting request from untrusted proxy")
+			sendError(w, http.StatusForbidden, ErrorResponse{Error: "requests must come through the authenticating proxy"})
+			return
+		}
+		email := strings.TrimSpace(r.Header.Get(p.cfg.EmailHeader))
+		if email == "" {
+			sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+			return
+		}
+		identity := Identity{
+			Email:       email,
+			DisplayName: strings.TrimSpace(r.Header.Get(p.cfg.NameHeader)),
+		}
+		for _, group := range strings.Split(r.Header.Get(p.cfg.GroupsHeader), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				identity.Groups = append(identity.Groups, group)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(contextWithIdentity(r.Context(), identity)))
+	})
+}
+
+func (p *proxyAuth) isTrusted(remoteAddr string) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap().WithZone("")
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{
+		TrustedCIDRs: []string{"10.0.0.0/8", "fd00::1"},
+		EmailHeader:  "X-Forwarded-Email",
+		NameHeader:   "X-Forwarded-Preferred-Username",
+		GroupsHeader: "X-Forwarded-Groups",
+	}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a := App{Logger: logger, proxy: auth}
+
+	cases := []struct {
+		Name             string
+		RemoteAddr       string
+		Headers          map[string]string
+		ExpectedStatus   int
+		ExpectedIdentity Identity
+	}{
+		{
+			Name:       "Trusted proxy",
+			RemoteAddr: "10.1.2.3:4321",
+			Headers: map[string]string{
+				"X-Forwarded-Email":              "user@example.com",
+				"X-Forwarded-Preferred-Username": "Test User",
+				"X-Forwarded-Groups":             "engineering, admins,",
+			},
+			ExpectedStatus: http.StatusOK,
+			ExpectedIdentity: Identity{
+				Email:       "user@example.com",
+				DisplayName: "Test User",
+				Groups:      []string{"engineering", "admins"},
+			},
+		},
+		{
+			Name:             "Trusted IPv6 proxy",
+			RemoteAddr:       "[fd00::1]:4321",
+			Headers:          map[string]string{"X-Forwarded-Email": "user@example.com"},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedIdentity: Identity{Email: "user@example.com"},
+		},
+		{
+			Name:             "IPv4 mapped address",
+			RemoteAddr:       "[::ffff:10.0.0.1]:4321",
+			Headers:          map[string]string{"X-Forwarded-Email": "user@example.com"},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedIdentity: Identity{Email: "user@example.com"},
+		},
+		{
+			Name:           "Untrusted client",
+			RemoteAddr:     "192.168.1.10:4321",
+			Headers:        map[string]string{"X-Forwarded-Email": "admin@example.com"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Name:           "Untrusted IPv6 client",
+			RemoteAddr:     "[fd00::2]:4321",
+			Headers:        map[string]string{"X-Forwarded-Email": "admin@example.com"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Name:           "Missing email",
+			RemoteAddr:     "10.1.2.3:4321",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/owned", nil)
+			r.RemoteAddr = tc.RemoteAddr
+			for key, value := range tc.Headers {
+				r.Header.Set(key, value)
+			}
+			var identity Identity
+			w := httptest.NewRecorder()
+			auth.RequireAccount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				email, err := a.getEmailFromRequest(r)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.ExpectedIdentity.Email, email)
+				identity, _ = IdentityFromContext(r.Context())
+			})).ServeHTTP(w, r)
+			assert.Equal(t, tc.ExpectedStatus, w.Code)
+			assert.Equal(t, tc.ExpectedIdentity, identity)
+		})
+	}
+
+	_, err = newProxyAuth(config.ProxyConfig{}, logger)
+	assert.Error(t, err)
+	_, err = newProxyAuth(config.ProxyConfig{TrustedCIDRs: []string{"not an address"}}, logger)
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// templateRegexp matches positional placeholders such as {1} in a link URL.
+var templateRegexp = regexp.MustCompile(`\{(\d+)\}`)
+
+// resolveLink finds the link for path, walking up one segment at a time until
+// a stored link matches (go/docs/setup -> go/docs). The unmatched trailing
+// segments are returned with their original case preserved.
+func resolveLink(ctx context.Context, s store.Store, path string) (store.Link, []string, error) {
+	return resolveLinkIn(ctx, s, "", path)
+}
+
+// resolveLinkIn is resolveLink for links named under namespace, so go/docs is
+// looked up as namespace/docs. The walk stops before the namespace itself.
+func resolveLinkIn(ctx context.Context, s store.Store, namespace string, path string) (store.Link, []string, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return store.Link{}, nil, store.ErrLinkNotFound
+	}
+	segments := strings.Split(path, "/")
+	for i := len(segments); i > 0; i-- {
+		name := strings.Join(segments[:i], "/")
+		if namespace != "" {
+			name = namespace + "/" + name
+		}
+		name, err := cleanLink(name)
+		if err != nil {
+			continue
+		}
+		link, err := s.GetLinkByName(ctx, name)
+		if errors.Is(err, store.ErrLinkNotFound) {
+			continue
+		}
+		if err != nil {
+			return store.Link{}, nil, err
+		}
+		return link, segments[i:], nil
+	}
+	return store.Link{}, nil, store.ErrLinkNotFound
+}
+
+// expandLinkURL builds the redirect target for target given the remaining
+// path segments and the original query string. URLs containing {1}, {2}, ...
+// or %s placeholders have them substituted; otherwise the remaining segments
+// are appended to the URL path. Placeholders in the path are path escaped and
+// those in the query or fragment are query escaped, so an argument can not add
+// query parameters. The query string is always passed through.
+func expandLinkURL(target string, args []string, rawQuery string) string {
+	path, rest := target, ""
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		path, rest = target[:i], target[i:]
+	}
+	path, pathTemplated := substituteArgs(path, args, url.PathEscape)
+	rest, restTemplated := substituteArgs(rest, args, url.QueryEscape)
+	target = path + rest
+	templated := pathTemplated || restTemplated
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	if !templated && len(args) > 0 {
+		u = u.JoinPath(args...)
+	}
+	if rawQuery != "" {
+		if u.RawQuery == "" {
+			u.RawQuery = rawQuery
+		} else {
+			u.RawQuery = u.RawQuery + "&" + rawQuery
+		}
+	}
+	return u.String()
+}
+
+// substituteArgs replaces the placeholders in part with args escaped by
+// escape, reporting whether part had any.
+func substituteArgs(part string, args []string, escape func(string) string) (string, bool) {
+	escaped := make([]string, len(args))
+	for i, arg := range args {
+		escaped[i] = escape(arg)
+	}
+	templated := false
+	if strings.Contains(part, "%s") {
+		templated = true
+		part = strings.ReplaceAll(part, "%s", strings.Join(escaped, "/"))
+	}
+	if templateRegexp.MatchString(part) {
+		templated = true
+		part = templateRegexp.ReplaceAllStringFunc(part, func(match string) string {
+			i, _ := strconv.Atoi(match[1 : len(match)-1])
+			if i < 1 || i > len(escaped) {
+				return ""
+			}
+			return escaped[i-1]
+		})
+	}
+	return part, templated
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandLinkURL(t *testing.T) {
+	cases := []struct {
+		Name     string
+		URL      string
+		Args     []string
+		Query    string
+		Expected string
+	}{
+		{
+			Name:     "Exact match",
+			URL:      "https://example.com/docs",
+			Expected: "https://example.com/docs",
+		},
+		{
+			Name:     "Append remaining path",
+			URL:      "https://example.com/docs",
+			Args:     []string{"setup", "Linux"},
+			Expected: "https://example.com/docs/setup/Linux",
+		},
+		{
+			Name:     "Append remaining path to trailing slash",
+			URL:      "https://example.com/docs/",
+			Args:     []string{"setup"},
+			Expected: "https://example.com/docs/setup",
+		},
+		{
+			Name:     "Pass through query",
+			URL:      "https://example.com/search",
+			Query:    "q=golang&page=2",
+			Expected: "https://example.com/search?q=golang&page=2",
+		},
+		{
+			Name:     "Merge query with existing query",
+			URL:      "https://example.com/search?source=golinks",
+			Args:     []string{"all"},
+			Query:    "q=golang",
+			Expected: "https://example.com/search/all?source=golinks&q=golang",
+		},
+		{
+			Name:     "Positional placeholders",
+			URL:      "https://jira.example.com/browse/{1}",
+			Args:     []string{"ABC-123"},
+			Expected: "https://jira.example.com/browse/ABC-123",
+		},
+		{
+			Name:     "Multiple positional placeholders",
+			URL:      "https://github.com/{1}/{2}/pulls",
+			Args:     []string{"imdevinc", "go-links"},
+			Expected: "https://github.com/imdevinc/go-links/pulls",
+		},
+		{
+			Name:     "Missing positional placeholder",
+			URL:      "https://jira.example.com/browse/{1}",
+			Expected: "https://jira.example.com/browse/",
+		},
+		{
+			Name:     "Printf placeholder with multiple segments",
+			URL:      "https://example.com/wiki/%s",
+			Args:     []string{"team", "oncall"},
+			Expected: "https://example.com/wiki/team/oncall",
+		},
+		{
+			Name:     "Placeholder in query",
+			URL:      "https://www.google.com/search?q={1}",
+			Args:     []string{"golang"},
+			Query:    "hl=en",
+			Expected: "https://www.google.com/search?q=golang&hl=en",
+		},
+		{
+			Name:     "Placeholder is escaped",
+			URL:      "https://example.com/{1}",
+			Args:     []string{"a b?c"},
+			Expected: "https://example.com/a%20b%3Fc",
+		},
+		{
+			Name:     "Placeholder in query can not add parameters",
+			URL:      "https://www.google.com/search?q=%s",
+			Args:     []string{"foo&bar=x"},
+			Expected: "https://www.google.com/search?q=foo%26bar%3Dx",
+		},
+		{
+			Name:     "Placeholders in path and query are escaped for their part",
+			URL:      "https://example.com/{1}?q={2}#{1}",
+			Args:     []string{"a b", "c d&e"},
+			Expected: "https://example.com/a%20b?q=c+d%26e#a+b",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, expandLinkURL(tc.URL, tc.Args, tc.Query))
+		})
+	}
+}
+
+func TestResolveLink(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"})
+	s.CreateLink(ctx, store.Link{Name: "docs/api", URL: "https://api.example.com"})
+	s.CreateLink(ctx, store.Link{Name: "jira", URL: "https://jira.example.com/browse/{1}"})
+
+	cases := []struct {
+		Name         string
+		Path         string
+		ExpectedName string
+		ExpectedArgs []string
+		ExpectedErr  error
+	}{
+		{
+			Name:         "Exact match",
+			Path:         "/docs",
+			ExpectedName: "docs",
+			ExpectedArgs: []string{},
+		},
+		{
+			Name:         "Case insensitive match",
+			Path:         "/DOCS/",
+			ExpectedName: "docs",
+			ExpectedArgs: []string{},
+		},
+		{
+			Name:         "Longest prefix wins",
+			Path:         "/docs/api/v1",
+			ExpectedName: "docs/api",
+			ExpectedArgs: []string{"v1"},
+		},
+		{
+			Name:         "Walk up to parent",
+			Path:         "/docs/setup/Linux",
+			ExpectedName: "docs",
+			ExpectedArgs: []string{"setup", "Linux"},
+		},
+		{
+			Name:         "Remaining segments keep invalid characters",
+			Path:         "/jira/ABC_123.md",
+			ExpectedName: "jira",
+			ExpectedArgs: []string{"ABC_123.md"},
+		},
+		{
+			Name:        "No match",
+			Path:        "/missing/setup",
+			ExpectedErr: store.ErrLinkNotFound,
+		},
+		{
+			Name:        "Empty path",
+			Path:        "/",
+			ExpectedErr: store.ErrLinkNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			link, args, err := resolveLink(ctx, s, tc.Path)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.ExpectedName, link.Name)
+			assert.Equal(t, tc.ExpectedArgs, args)
+		})
+	}
+}
+
+func TestHandleGetLink(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	s.CreateLink(ctx, store.Link{Name: "jira", URL: "https://jira.example.com/browse/{1}"})
+	a := App{
+		Store:  s,
+		Logger: slog.Default(),
+		config: &config.Config{FQDN: "go.example.com"},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/jira/ABC-123?focus=comments", nil)
+	r = mux.SetURLVars(r, map[string]string{"link": "/jira/ABC-123"})
+	w := httptest.NewRecorder()
+	a.handleLink(w, r)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://jira.example.com/browse/ABC-123?focus=comments", w.Header().Get("Location"))
+
+	link, _ := s.GetLinkByName(ctx, "jira")
+	assert.Equal(t, 1, link.Views)
+
+	r = httptest.NewRequest(http.MethodGet, "/missing/ABC-123", nil)
+	r = mux.SetURLVars(r, map[string]string{"link": "/missing/ABC-123"})
+	w = httptest.NewRecorder()
+	a.handleLink(w, r)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "//go.example.com", w.Header().Get("Location"))
+}
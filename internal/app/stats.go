@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+const maxStatsBuckets = 366
+
+var defaultStatsBuckets = map[store.StatsInterval]int{
+	store.StatsIntervalDay:  30,
+	store.StatsIntervalWeek: 12,
+}
+
+type StatsResponse struct {
+	Link     string              `json:"link"`
+	Interval store.StatsInterval `json:"interval"`
+	Total    int                 `json:"total"`
+	Buckets  []store.ClickBucket `json:"buckets"`
+}
+
+// recordClick stores a click event for link. Failures are logged and never
+// block the redirect.
+func (a *App) recordClick(r *http.Request, link store.Link) {
+	if a.Analytics == nil {
+		return
+	}
+	click := store.Click{
+		Link:      link.Name,
+		Timestamp: time.Now(),
+	}
+	if referrer, err := url.Parse(r.Referer()); err == nil {
+		click.Referrer = referrer.Hostname()
+	}
+	if identity, ok := IdentityFromContext(r.Context()); ok {
+		click.User = identity.Email
+	}
+	if err := a.Analytics.RecordClick(r.Context(), click); err != nil {
+		a.Logger.Error(err.Error())
+	}
+}
+
+// purgeClicks periodically removes clicks older than retention. It returns
+// when ctx is cancelled.
+func (a *App) purgeClicks(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(min(retention, maxPurgeInterval))
+	defer ticker.Stop()
+	for {
+		count, err := a.Analytics.PurgeClicks(ctx, time.Now().Add(-retention))
+		if err != nil {
+			a.Logger.Error(err.Error())
+		} else if count > 0 {
+			a.Logger.With("count", count).Info("purged clicks")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleGetLinkStats serves /api/links/{name}/stats?interval=day|week&buckets=N.
+func (a *App) handleGetLinkStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	if a.Analytics == nil {
+		sendError(w, http.StatusNotImplemented, ErrorResponse{Error: "analytics are not supported by this store"})
+		return
+	}
+	name, err := cleanLink(strings.TrimSuffix(r.URL.Path[len("/api/links/"):], "/stats"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	interval := store.StatsIntervalDay
+	if v := r.URL.Query().Get("interval"); v != "" {
+		interval, err = store.ParseStatsInterval(v)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	count := defaultStatsBuckets[interval]
+	if v := r.URL.Query().Get("buckets"); v != "" {
+		count, err = strconv.Atoi(v)
+		if err != nil || count < 1 || count > maxStatsBuckets {
+			sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid bucket count"})
+			return
+		}
+	}
+
+	link, err := a.Store.GetLinkByName(r.Context(), name)
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	current := interval.Truncate(time.Now())
+	since := interval.Add(current, -(count - 1))
+	buckets, err := a.Analytics.GetClickStats(r.Context(), link.Name, interval, since)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	resp := StatsResponse{
+		Link:     link.Name,
+		Interval: interval,
+		Buckets:  fillBuckets(buckets, interval, since, current),
+	}
+	for _, b := range resp.Buckets {
+		resp.Total += b.Clicks
+	}
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+}
+
+// fillBuckets returns one bucket per interval between since and last,
+// inclusive, using zero counts where the store returned nothing.
+func fillBuckets(buckets []store.ClickBucket, interval store.StatsInterval, since time.Time, last time.Time) []store.ClickBucket {
+	byStart := map[time.Time]store.ClickBucket{}
+	for _, b := range buckets {
+		byStart[b.Start.UTC()] = b
+	}
+	result := []store.ClickBucket{}
+	for start := since; !start.After(last); start = interval.Add(start, 1) {
+		b, ok := byStart[start]
+		if !ok {
+			b = store.ClickBucket{Start: start}
+		}
+		result = append(result, b)
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+)
+
+// certReloadInterval is how often the certificate files are checked for changes.
+const certReloadInterval = 30 * time.Second
+
+// certReloader serves a certificate from files, reloading it when the files
+// change so renewed certificates are used without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	// certInfo and keyInfo are the state of the files when they were last
+	// loaded, used to detect changes.
+	certInfo os.FileInfo
+	keyInfo  os.FileInfo
+}
+
+func newCertReloader(certFile string, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// reload loads the certificate if either file changed since it was last
+// loaded, reporting whether it did. The current certificate is kept when the
+// files can not be loaded, such as while they are being replaced.
+func (c *certReloader) reload() (bool, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mu.RLock()
+	unchanged := sameFile(certInfo, c.certInfo) && sameFile(keyInfo, c.keyInfo)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert, c.certInfo, c.keyInfo = &cert, certInfo, keyInfo
+	return true, nil
+}
+
+// watch reloads the certificate on interval until ctx is done.
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.reload()
+			if err != nil {
+				c.logger.With("error", err).Warn("failed to reload tls certificate, keeping the current one")
+			} else if reloaded {
+				c.logger.With("path", c.certFile).Info("tls certificate changed on disk, reloaded")
+			}
+		}
+	}
+}
+
+func sameFile(a os.FileInfo, b os.FileInfo) bool {
+	return b != nil && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// hstsHandler sets the Strict-Transport-Security header on every response.
+func hstsHandler(cfg config.HSTSConfig, next http.Handler) http.Handler {
+	directives := []string{"max-age=" + strconv.Itoa(int(cfg.MaxAge.Seconds()))}
+	if cfg.IncludeSubdomains {
+		directives = append(directives, "includeSubDomains")
+	}
+	if cfg.Preload {
+		directives = append(directives, "preload")
+	}
+	value := strings.Join(directives, "; ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpsRedirectHandler redirects every request to the same path over https.
+// Accepted hosts, such as namespace hosts, keep their host so their namespace
+// still applies, and other hosts such as http://go go to FQDN.
+func (a *App) httpsRedirectHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := a.config.FQDN
+		if a.hosts != nil && a.hosts.accepts(r.Host) {
+			host = r.Host
+			if hostname, _, err := net.SplitHostPort(host); err == nil {
+				host = hostname
+			}
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+		}
+		w.Header().Set("Location", fmt.Sprintf("https://%s%s", host, r.URL.RequestURI()))
+		w.WriteHeader(http.StatusMovedPermanently)
+	})
+}
+
+// serveHTTPSRedirects serves httpsRedirectHandler on port until the returned
+// server is shut down.
+func (a *App) serveHTTPSRedirects(port int) *http.Server {
+	server := a.newServer(port, a.httpsRedirectHandler())
+	go func() {
+		a.Logger.With("port", port).Info("starting https redirect server")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.Logger.With("port", port).Error(err.Error())
+		}
+	}()
+	return server
+}
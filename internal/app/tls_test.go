@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a self-signed certificate for name to certFile and keyFile.
+func writeCert(t *testing.T, name string, certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, "go.example.com", certFile, keyFile)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	certs, err := newCertReloader(certFile, keyFile, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	commonName := func() string {
+		cert, err := certs.GetCertificate(nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "go.example.com", commonName())
+	reloaded, err := certs.reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded, "the files did not change")
+
+	// a renewed certificate is picked up
+	writeCert(t, "renewed.example.com", certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, future, future))
+	assert.NoError(t, os.Chtimes(keyFile, future, future))
+	reloaded, err = certs.reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "renewed.example.com", commonName())
+
+	// a broken certificate keeps the current one
+	assert.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	_, err = certs.reload()
+	assert.Error(t, err)
+	assert.Equal(t, "renewed.example.com", commonName())
+
+	_, err = newCertReloader(filepath.Join(dir, "missing.crt"), keyFile, logger)
+	assert.Error(t, err)
+}
+
+func TestHSTS(t *testing.T) {
+	cases := []struct {
+		cfg      config.HSTSConfig
+		expected string
+	}{
+		{config.HSTSConfig{MaxAge: 365 * 24 * time.Hour}, "max-age=31536000"},
+		{config.HSTSConfig{MaxAge: time.Hour, IncludeSubdomains: true, Preload: true}, "max-age=3600; includeSubDomains; preload"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		hstsHandler(tc.cfg, http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, tc.expected, w.Header().Get("Strict-Transport-Security"))
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	a := App{config: &config.Config{FQDN: "go.example.com"}}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://go/docs/api?q=links", nil)
+	a.httpsRedirectHandler().ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://go.example.com/docs/api?q=links", w.Header().Get("Location"))
+}
+
+func TestHTTPSRedirectNamespaceHost(t *testing.T) {
+	cfg := &config.Config{FQDN: "go.example.com", Hosts: config.HostsConfig{
+		Redirect:   config.HostRedirectPermanent,
+		Namespaces: map[string]string{"eng.go": "eng"},
+	}}
+	h, err := newHosts(cfg.FQDN, cfg.Hosts)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a := App{config: cfg, hosts: h}
+	cases := map[string]string{
+		"http://eng.go/foo":            "https://eng.go/foo",
+		"http://eng.go:8080/foo":       "https://eng.go/foo",
+		"http://go.example.com/foo?q=": "https://go.example.com/foo?q=",
+		"http://go/foo":                "https://go.example.com/foo",
+	}
+	for target, expected := range cases {
+		w := httptest.NewRecorder()
+		a.httpsRedirectHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, expected, w.Header().Get("Location"), target)
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, "go.example.com", certFile, keyFile)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	httpPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := &App{Store: store.NewMemoryStore(), Logger: logger}
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Start(ctx, &config.Config{
+			AuthMode: config.AuthModeNone,
+			Port:     port,
+			FQDN:     "go.example.com",
+			Server:   config.ServerConfig{ShutdownTimeout: time.Second},
+			Hosts:    config.HostsConfig{Redirect: config.HostRedirectPermanent, Namespaces: map[string]string{"eng.go": "eng"}},
+			TLS: config.TLSConfig{
+				CertFile: certFile,
+				KeyFile:  keyFile,
+				HTTPPort: httpPort,
+				HSTS:     config.HSTSConfig{MaxAge: time.Hour},
+			},
+		})
+	}()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	var resp *http.Response
+	assert.Eventually(t, func() bool {
+		resp, err = client.Get("https://127.0.0.1:" + strconv.Itoa(port) + "/healthz")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "max-age=3600", resp.Header.Get("Strict-Transport-Security"))
+	assert.Equal(t, "go.example.com", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// plain http to a namespace host keeps the host so the namespace applies
+	plain := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:"+strconv.Itoa(httpPort)+"/foo", nil)
+	req.Host = "eng.go"
+	var redirect *http.Response
+	assert.Eventually(t, func() bool {
+		redirect, err = plain.Do(req)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	if assert.NoError(t, err) {
+		redirect.Body.Close()
+		assert.Equal(t, http.StatusMovedPermanently, redirect.StatusCode)
+		assert.Equal(t, "https://eng.go/foo", redirect.Header.Get("Location"))
+	}
+
+	err = (&App{Store: store.NewMemoryStore(), Logger: logger}).Start(context.Background(), &config.Config{
+		AuthMode: config.AuthModeNone,
+		TLS:      config.TLSConfig{CertFile: certFile},
+	})
+	assert.EqualError(t, err, "both tls cert file and key file must be set")
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// tokenPrefix marks personal access tokens so they are easy to recognize,
+// for example by secret scanners.
+const tokenPrefix = "gl_"
+
+type tokenKey struct{}
+
+// CreateTokenInput is the payload for POST /api/tokens.
+type CreateTokenInput struct {
+	Name    string           `json:"name"`
+	Scope   store.TokenScope `json:"scope"`
+	Expires *time.Time       `json:"expires_at,omitempty"`
+}
+
+// CreateTokenResponse includes the token secret, which is only ever shown
+// when the token is created.
+type CreateTokenResponse struct {
+	store.APIToken
+	Token string `json:"token"`
+}
+
+// tokenFromContext returns the token a request was authenticated with, if any.
+func tokenFromContext(ctx context.Context) (store.APIToken, bool) {
+	token, ok := ctx.Value(tokenKey{}).(store.APIToken)
+	return token, ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(secret), true
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// requireToken authenticates requests carrying a personal access token,
+// attaching the owner's Identity to the request context. Read tokens are
+// limited to requests that do not change anything.
+func (a *App) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, _ := bearerToken(r)
+		token, err := a.Tokens.GetTokenByHash(r.Context(), hashToken(secret))
+		if err != nil && !errors.Is(err, store.ErrTokenNotFound) {
+			a.Logger.Error(err.Error())
+			sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+			return
+		}
+		if err != nil || !strings.HasPrefix(secret, tokenPrefix) || token.Expired(time.Now()) {
+			sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid or expired token"})
+			return
+		}
+		if token.Scope != store.TokenScopeWrite && !isReadOnly(r) {
+			sendError(w, http.StatusForbidden, ErrorResponse{Error: "token does not have write scope"})
+			return
+		}
+		ctx := contextWithIdentity(r.Context(), Identity{Email: token.Owner})
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, tokenKey{}, token)))
+	})
+}
+
+// isReadOnly reports whether r only reads links.
+func isReadOnly(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return strings.EqualFold(r.URL.Path, "/api/query")
+	}
+	return false
+}
+
+// handleTokens lists (GET) and creates (POST) the caller's tokens. Tokens can
+// only be managed from a browser session, never with another token.
+func (a *App) handleTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if a.Tokens == nil {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "tokens are not supported by this store"})
+		return
+	}
+	if _, ok := tokenFromContext(r.Context()); ok {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "tokens can not be managed with a token"})
+		return
+	}
+	email, err := a.getEmailFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := a.Tokens.GetTokens(r.Context(), email)
+		if err != nil {
+			a.Logger.Error(err.Error())
+			sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+			return
+		}
+		err = json.NewEncoder(w).Encode(tokens)
+		if err != nil {
+			a.Logger.Error(err.Error())
+			sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+			return
+		}
+	case http.MethodPost:
+		a.handleCreateToken(w, r, email)
+	default:
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+	}
+}
+
+func (a *App) handleCreateToken(w http.ResponseWriter, r *http.Request, email string) {
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	input := CreateTokenInput{}
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid payload"})
+		return
+	}
+	if input.Scope == "" {
+		input.Scope = store.TokenScopeRead
+	}
+	if input.Scope != store.TokenScopeRead && input.Scope != store.TokenScopeWrite {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "scope must be read or write"})
+		return
+	}
+	now := time.Now().UTC()
+	if input.Expires != nil && !input.Expires.After(now) {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "expires_at must be in the future"})
+		return
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	resp := CreateTokenResponse{
+		APIToken: store.APIToken{
+			ID:      randomString()[:16],
+			Owner:   email,
+			Name:    input.Name,
+			Scope:   input.Scope,
+			Created: now,
+			Expires: input.Expires,
+		},
+		Token: tokenPrefix + base64.RawURLEncoding.EncodeToString(secret),
+	}
+	resp.Hash = hashToken(resp.Token)
+	err = a.Tokens.CreateToken(r.Context(), resp.APIToken)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		a.Logger.Error(err.Error())
+	}
+}
+
+// handleDeleteToken revokes one of the caller's tokens.
+func (a *App) handleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if a.Tokens == nil {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "tokens are not supported by this store"})
+		return
+	}
+	if r.Method != http.MethodDelete {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	if _, ok := tokenFromContext(r.Context()); ok {
+		sendError(w, http.StatusForbidden, ErrorResponse{Error: "tokens can not be managed with a token"})
+		return
+	}
+	email, err := a.getEmailFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	err = a.Tokens.DeleteToken(r.Context(), email, r.URL.Path[len("/api/tokens/"):])
+	if errors.Is(err, store.ErrTokenNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "token not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := newProxyAuth(config.ProxyConfig{TrustedCIDRs: []string{"10.0.0.1"}, EmailHeader: "X-Forwarded-Email"}, logger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := store.NewMemoryStore()
+	a := App{Store: s, Tokens: s, Logger: logger, proxy: auth, config: &config.Config{FQDN: "go"}}
+	handler := a.authWrapper(http.HandlerFunc(a.handleApi))
+
+	// session requests come through the proxy, token requests straight from a script
+	serve := func(method string, target string, body string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token == "" {
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header.Set("X-Forwarded-Email", "user@example.com")
+		} else {
+			r.RemoteAddr = "192.168.1.10:1234"
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	create := func(body string) CreateTokenResponse {
+		w := serve(http.MethodPost, "/api/tokens", body, "")
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		resp := CreateTokenResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.True(t, strings.HasPrefix(resp.Token, tokenPrefix))
+		assert.NotContains(t, w.Body.String(), hashToken(resp.Token))
+		return resp
+	}
+
+	read := create(`{"name": "ci"}`)
+	write := create(`{"name": "cli", "scope": "write"}`)
+	assert.Equal(t, store.TokenScopeRead, read.Scope)
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com", CreatedBy: "user@example.com"}))
+
+	w := serve(http.MethodGet, "/api/owned", "", read.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"docs"`)
+	w = serve(http.MethodPost, "/api/query", `{"query": "docs"}`, read.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(http.MethodPost, "/api/import", `[]`, read.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(http.MethodGet, "/api/owned", "", write.Token+"x")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// tokens can not mint or revoke tokens, even with write scope
+	w = serve(http.MethodPost, "/api/tokens", `{"scope": "write"}`, write.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(http.MethodDelete, "/api/tokens/"+read.ID, "", write.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(http.MethodGet, "/api/tokens", "", "")
+	tokens := []store.APIToken{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&tokens))
+	assert.Len(t, tokens, 2)
+
+	w = serve(http.MethodDelete, "/api/tokens/"+read.ID, "", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(http.MethodGet, "/api/owned", "", read.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve(http.MethodPost, "/api/tokens", `{"scope": "admin"}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(http.MethodPost, "/api/tokens", `{"expires_at": "2000-01-01T00:00:00Z"}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	expiring := create(`{"expires_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`)
+	past := time.Now().Add(-time.Minute)
+	token, err := s.GetTokenByHash(ctx, hashToken(expiring.Token))
+	if assert.NoError(t, err) {
+		assert.NoError(t, s.DeleteToken(ctx, token.Owner, token.ID))
+		token.Expires = &past
+		assert.NoError(t, s.CreateToken(ctx, token))
+	}
+	w = serve(http.MethodGet, "/api/owned", "", expiring.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
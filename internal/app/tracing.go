@@ -0,0 +1,21 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/imdevinc/go-links/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// traced records a span for every request to next, continuing the trace of
+// the caller when the request carries W3C trace context headers. Spans are
+// named after the same routes as the request metrics.
+func (a *App) traced(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "go-links",
+		otelhttp.WithTracerProvider(a.Tracing),
+		otelhttp.WithPropagators(tracing.Propagator),
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return metricsMethod(r.Method) + " " + metricsRoute(r.URL.Path)
+		}),
+	)
+}
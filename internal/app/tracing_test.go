@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s := store.NewMemoryStore()
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com"}))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := App{Store: store.NewTracedStore(s, provider), Tracing: provider, Logger: logger, config: &config.Config{FQDN: "go"}}
+	router := mux.NewRouter()
+	router.Path("/{link:.*}").Handler(a.authWrapper(http.HandlerFunc(a.handleLink)))
+	handler := a.traced(router)
+
+	r := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 3) {
+		t.FailNow()
+	}
+	// spans end children first
+	lookup, views, request := spans[0], spans[1], spans[2]
+	assert.Equal(t, "store.GetLinkByName", lookup.Name)
+	assert.Equal(t, "store.IncrementLinkViews", views.Name)
+	assert.Equal(t, "GET /{link}", request.Name)
+	assert.Equal(t, trace.SpanKindServer, request.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent.SpanID().String())
+	assert.True(t, request.Parent.IsRemote())
+	assert.Equal(t, request.SpanContext.SpanID(), lookup.Parent.SpanID())
+	assert.Equal(t, request.SpanContext.SpanID(), views.Parent.SpanID())
+}
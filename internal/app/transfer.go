@@ -0,0 +1,493 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+type TransferFormat string
+
+const (
+	FormatJSON      TransferFormat = "json"
+	FormatCSV       TransferFormat = "csv"
+	FormatBookmarks TransferFormat = "html"
+)
+
+// maxImportSize limits the size of an uploaded import file.
+const maxImportSize = 10 << 20
+
+var csvHeader = []string{"name", "url", "description", "views", "created_by", "created_at", "updated_at", "owners"}
+
+// csvOwnerSeparator separates the owners in the owners column of a CSV file.
+const csvOwnerSeparator = ";"
+
+var (
+	bookmarkRegexp     = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>(\s*<dd>([^<]*))?`)
+	attributeRegexp    = regexp.MustCompile(`(?is)([a-z_]+)\s*=\s*"([^"]*)"`)
+	invalidSlugRegexp  = regexp.MustCompile(`[^a-z0-9/]+`)
+	repeatedDashRegexp = regexp.MustCompile(`-{2,}`)
+)
+
+// ImportRow is the outcome of importing a single entry of an import file.
+type ImportRow struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type ImportResponse struct {
+	DryRun   bool        `json:"dry_run"`
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// importEntry is a parsed but not yet validated entry of an import file.
+type importEntry struct {
+	row  int
+	link store.Link
+	err  error
+}
+
+// linkImport is the state of a single import request.
+type linkImport struct {
+	email string
+	// keep keeps the views, timestamps and owners from the file instead of
+	// making the importer the owner of every link, for admins restoring a
+	// backup.
+	keep   bool
+	dryRun bool
+	// existing holds every link name, including disabled links, for dry runs.
+	existing map[string]struct{}
+	// seen holds the names imported so far.
+	seen map[string]struct{}
+}
+
+func parseTransferFormat(r *http.Request) (TransferFormat, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(contentType, "text/csv"):
+			format = string(FormatCSV)
+		case strings.HasPrefix(contentType, "text/html"):
+			format = string(FormatBookmarks)
+		default:
+			format = string(FormatJSON)
+		}
+	}
+	switch TransferFormat(format) {
+	case FormatJSON, FormatCSV, FormatBookmarks:
+		return TransferFormat(format), nil
+	}
+	return "", fmt.Errorf("unknown format %q", format)
+}
+
+// handleExport serves /api/export?format=json|csv|html&scope=all|owned.
+func (a *App) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	format, err := parseTransferFormat(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	var links []store.Link
+	switch r.URL.Query().Get("scope") {
+	case "", "all":
+		links = []store.Link{}
+		err = a.Store.WalkLinks(r.Context(), func(link store.Link) error {
+			if !link.Disabled {
+				links = append(links, link)
+			}
+			return nil
+		})
+	case "owned":
+		identity, identityErr := a.getIdentityFromRequest(r)
+		if identityErr != nil {
+			a.Logger.Error(identityErr.Error())
+			sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+			return
+		}
+		links, err = a.Store.GetOwnedLinks(r.Context(), identity.owners())
+	default:
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "unknown export scope"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	buf := bytes.Buffer{}
+	switch format {
+	case FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(&buf).Encode(links)
+	case FormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		err = writeCSV(&buf, links)
+	case FormatBookmarks:
+		w.Header().Set("Content-Type", "text/html")
+		err = writeBookmarks(&buf, links)
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="golinks.%s"`, format))
+	w.Write(buf.Bytes())
+}
+
+// handleImport serves /api/import?format=json|csv|html&dry_run=true. Every
+// entry is validated and created independently; failures are reported per row.
+func (a *App) handleImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	format, err := parseTransferFormat(r)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	imp := &linkImport{
+		email:  identity.Email,
+		keep:   a.isAdmin(identity),
+		dryRun: dryRun,
+		seen:   map[string]struct{}{},
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	defer r.Body.Close()
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: "failed to read payload"})
+		return
+	}
+
+	var entries []importEntry
+	switch format {
+	case FormatJSON:
+		entries, err = readJSON(body)
+	case FormatCSV:
+		entries, err = readCSV(body)
+	case FormatBookmarks:
+		entries = readBookmarks(body)
+	}
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid payload: %s", err)})
+		return
+	}
+
+	if dryRun {
+		// GetLinkByName can not see disabled links, which still hold their name
+		imp.existing = map[string]struct{}{}
+		err = a.Store.WalkLinks(r.Context(), func(link store.Link) error {
+			imp.existing[strings.ToLower(link.Name)] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			a.Logger.Error(err.Error())
+			sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+			return
+		}
+	}
+
+	resp := ImportResponse{DryRun: dryRun, Rows: []ImportRow{}}
+	for _, entry := range entries {
+		row := ImportRow{Row: entry.row, Name: entry.link.Name}
+		err := entry.err
+		if err == nil {
+			row.Name, err = a.importLink(r, imp, entry.link)
+		}
+		if err != nil {
+			row.Error = err.Error()
+			resp.Failed++
+		} else {
+			resp.Imported++
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+}
+
+// importLink validates link and creates it on behalf of the importer,
+// returning the cleaned link name.
+func (a *App) importLink(r *http.Request, imp *linkImport, link store.Link) (string, error) {
+	name, err := cleanLink(link.Name)
+	if err != nil {
+		return link.Name, err
+	}
+	if staticRegexp.MatchString(name) {
+		return name, fmt.Errorf("name is reserved")
+	}
+	if err := validateURL(link.URL); err != nil {
+		return name, err
+	}
+	if _, ok := imp.seen[name]; ok {
+		return name, fmt.Errorf("duplicate link in import")
+	}
+	imp.seen[name] = struct{}{}
+
+	imported := store.Link{
+		Name:        name,
+		URL:         link.URL,
+		Description: link.Description,
+		CreatedBy:   imp.email,
+	}
+	if imp.keep {
+		imported.Views = link.Views
+		imported.Created = link.Created
+		imported.Updated = link.Updated
+		imported.Owners = link.Owners
+		if link.CreatedBy != "" {
+			imported.CreatedBy = link.CreatedBy
+		}
+		if imported.Created.IsZero() {
+			imported.Created = time.Now()
+		}
+		if imported.Updated.IsZero() {
+			imported.Updated = imported.Created
+		}
+	}
+	if imp.dryRun {
+		if _, ok := imp.existing[name]; ok {
+			return name, fmt.Errorf("link already exists")
+		}
+		return name, nil
+	}
+	if imp.keep {
+		err = a.Store.ImportLink(r.Context(), imported, false)
+	} else {
+		err = a.Store.CreateLink(r.Context(), imported)
+	}
+	if errors.Is(err, store.ErrIDExists) {
+		return name, fmt.Errorf("link already exists")
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		return name, fmt.Errorf("internal server error")
+	}
+	created := a.currentLink(r.Context(), name, imported)
+	a.recordAudit(r, store.AuditActionCreate, name, nil, created)
+	a.recordRevision(r, nil, *created)
+	return name, nil
+}
+
+// validateURL checks that raw is an absolute http or https URL once its
+// placeholders, such as %s, are filled in, since %s on its own is not a valid
+// escape.
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("url is empty")
+	}
+	filled, _ := substituteArgs(raw, []string{"placeholder"}, url.PathEscape)
+	u, err := url.Parse(filled)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url is invalid")
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, links []store.Link) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, link := range links {
+		err := cw.Write([]string{
+			link.Name,
+			link.URL,
+			link.Description,
+			strconv.Itoa(link.Views),
+			link.CreatedBy,
+			link.Created.UTC().Format(time.RFC3339),
+			link.Updated.UTC().Format(time.RFC3339),
+			strings.Join(link.Owners, csvOwnerSeparator),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeBookmarks(w io.Writer, links []store.Link) error {
+	_, err := io.WriteString(w, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>go-links</TITLE>
+<H1>go-links</H1>
+<DL><p>
+`)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		_, err = fmt.Fprintf(w, "    <DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\" SHORTCUTURL=\"%s\">%s</A>\n",
+			html.EscapeString(link.URL), link.Created.Unix(), link.Updated.Unix(), html.EscapeString(link.Name), html.EscapeString(link.Name))
+		if err != nil {
+			return err
+		}
+		if link.Description != "" {
+			_, err = fmt.Fprintf(w, "    <DD>%s\n", html.EscapeString(link.Description))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = io.WriteString(w, "</DL><p>\n")
+	return err
+}
+
+func readJSON(body []byte) ([]importEntry, error) {
+	var links []store.Link
+	if err := json.Unmarshal(body, &links); err != nil {
+		return nil, err
+	}
+	entries := make([]importEntry, 0, len(links))
+	for i, link := range links {
+		entries = append(entries, importEntry{row: i + 1, link: link})
+	}
+	return entries, nil
+}
+
+// readCSV reads a CSV file with a header row. Only the name and url columns
+// are required.
+func readCSV(body []byte) ([]importEntry, error) {
+	cr := csv.NewReader(bytes.NewReader(body))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"name", "url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entries := []importEntry{}
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			entries = append(entries, importEntry{row: row, err: err})
+			continue
+		}
+		link := store.Link{
+			Name:        field(record, "name"),
+			URL:         field(record, "url"),
+			Description: field(record, "description"),
+			CreatedBy:   field(record, "created_by"),
+		}
+		entry := importEntry{row: row}
+		if v := field(record, "views"); v != "" {
+			link.Views, err = strconv.Atoi(v)
+			if err != nil {
+				entry.err = fmt.Errorf("invalid views")
+			}
+		}
+		for column, value := range map[string]*time.Time{"created_at": &link.Created, "updated_at": &link.Updated} {
+			if v := field(record, column); v != "" {
+				*value, err = time.Parse(time.RFC3339, v)
+				if err != nil {
+					entry.err = fmt.Errorf("invalid %s", column)
+				}
+			}
+		}
+		if v := field(record, "owners"); v != "" {
+			for _, owner := range strings.Split(v, csvOwnerSeparator) {
+				if owner = strings.TrimSpace(owner); owner != "" {
+					link.Owners = append(link.Owners, owner)
+				}
+			}
+		}
+		entry.link = link
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readBookmarks reads a Netscape bookmarks file as exported by browsers. The
+// link name comes from the SHORTCUTURL keyword when present, otherwise from
+// the bookmark title.
+func readBookmarks(body []byte) []importEntry {
+	entries := []importEntry{}
+	for i, match := range bookmarkRegexp.FindAllSubmatch(body, -1) {
+		attributes := map[string]string{}
+		for _, attr := range attributeRegexp.FindAllSubmatch(match[1], -1) {
+			attributes[strings.ToLower(string(attr[1]))] = html.UnescapeString(string(attr[2]))
+		}
+		title := strings.TrimSpace(html.UnescapeString(string(match[2])))
+		description := strings.TrimSpace(html.UnescapeString(string(match[4])))
+		name := attributes["shortcuturl"]
+		if name == "" {
+			name = slugify(title)
+			if description == "" {
+				description = title
+			}
+		}
+		link := store.Link{
+			Name:        name,
+			URL:         attributes["href"],
+			Description: description,
+		}
+		for attribute, value := range map[string]*time.Time{"add_date": &link.Created, "last_modified": &link.Updated} {
+			if seconds, err := strconv.ParseInt(attributes[attribute], 10, 64); err == nil && seconds > 0 {
+				*value = time.Unix(seconds, 0).UTC()
+			}
+		}
+		entries = append(entries, importEntry{row: i + 1, link: link})
+	}
+	return entries
+}
+
+// slugify turns a bookmark title into a valid link name.
+func slugify(title string) string {
+	slug := invalidSlugRegexp.ReplaceAllString(strings.ToLower(title), "-")
+	slug = repeatedDashRegexp.ReplaceAllString(slug, "-")
+	return strings.Trim(slug, "-/")
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTransferApp() (App, store.Store) {
+	s := store.NewMemoryStore()
+	return App{
+		Store:  s,
+		Logger: slog.Default(),
+		config: &config.Config{FQDN: "go.example.com"},
+	}, s
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []TransferFormat{FormatJSON, FormatCSV, FormatBookmarks} {
+		t.Run(string(format), func(t *testing.T) {
+			src, s := newTransferApp()
+			s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs?a=1&b=2", Description: `Team "docs", & more`})
+			s.CreateLink(ctx, store.Link{Name: "team/oncall", URL: "https://example.com/oncall"})
+			s.CreateLink(ctx, store.Link{Name: "deleted", URL: "https://example.com/deleted"})
+			s.DisableLink(ctx, "deleted")
+
+			w := httptest.NewRecorder()
+			src.handleExport(w, httptest.NewRequest(http.MethodGet, "/api/export?format="+string(format), nil))
+			if !assert.Equal(t, http.StatusOK, w.Code) {
+				t.FailNow()
+			}
+
+			dst, d := newTransferApp()
+			w2 := httptest.NewRecorder()
+			dst.handleImport(w2, httptest.NewRequest(http.MethodPost, "/api/import?format="+string(format), w.Body))
+			if !assert.Equal(t, http.StatusOK, w2.Code) {
+				t.FailNow()
+			}
+			resp := ImportResponse{}
+			json.NewDecoder(w2.Body).Decode(&resp)
+			assert.Equal(t, 2, resp.Imported)
+			assert.Equal(t, 0, resp.Failed)
+
+			docs, err := d.GetLinkByName(ctx, "docs")
+			if assert.NoError(t, err) {
+				assert.Equal(t, "https://example.com/docs?a=1&b=2", docs.URL)
+				assert.Equal(t, `Team "docs", & more`, docs.Description)
+				assert.Equal(t, "untracked", docs.CreatedBy)
+			}
+			_, err = d.GetLinkByName(ctx, "team/oncall")
+			assert.NoError(t, err)
+			_, err = d.GetLinkByName(ctx, "deleted")
+			assert.ErrorIs(t, err, store.ErrLinkNotFound)
+		})
+	}
+}
+
+func TestImportRowErrors(t *testing.T) {
+	ctx := context.Background()
+	a, s := newTransferApp()
+	s.CreateLink(ctx, store.Link{Name: "existing", URL: "https://example.com"})
+	s.CreateLink(ctx, store.Link{Name: "deleted", URL: "https://example.com"})
+	s.DisableLink(ctx, "deleted")
+	body := `name,url,description
+Valid,https://example.com/valid,A valid link
+-invalid,https://example.com,
+existing,https://example.com/other,
+nourl,,
+badurl,javascript:alert(1),
+valid,https://example.com/again,
+api/popular,https://example.com,
+Deleted,https://example.com/deleted,
+wiki,https://wiki.example.com/%s,
+jira,https://jira.example.com/browse/{1}?focus={2},
+`
+	r := httptest.NewRequest(http.MethodPost, "/api/import?dry_run=true", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	a.handleImport(w, r)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	resp := ImportResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	assert.Equal(t, ImportResponse{
+		DryRun:   true,
+		Imported: 3,
+		Failed:   7,
+		Rows: []ImportRow{
+			{Row: 1, Name: "valid"},
+			{Row: 2, Name: "-invalid", Error: "name input is invalid"},
+			{Row: 3, Name: "existing", Error: "link already exists"},
+			{Row: 4, Name: "nourl", Error: "url is empty"},
+			{Row: 5, Name: "badurl", Error: "url is invalid"},
+			{Row: 6, Name: "valid", Error: "duplicate link in import"},
+			{Row: 7, Name: "api/popular", Error: "name is reserved"},
+			{Row: 8, Name: "deleted", Error: "link already exists"},
+			{Row: 9, Name: "wiki"},
+			{Row: 10, Name: "jira"},
+		},
+	}, resp)
+	_, err := s.GetLinkByName(ctx, "valid")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+}
+
+func TestReadBookmarks(t *testing.T) {
+	body := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><H3>Work</H3>
+    <DL><p>
+        <DT><A HREF="https://jira.example.com" ADD_DATE="1700000000">Jira Board &amp; Tickets</A>
+        <DT><A HREF="https://wiki.example.com" SHORTCUTURL="wiki">Wiki</A>
+        <DD>Team wiki
+    </DL><p>
+</DL><p>`
+	entries := readBookmarks([]byte(body))
+	assert.Equal(t, []importEntry{
+		{row: 1, link: store.Link{Name: "jira-board-tickets", URL: "https://jira.example.com", Description: "Jira Board & Tickets", Created: time.Unix(1700000000, 0).UTC()}},
+		{row: 2, link: store.Link{Name: "wiki", URL: "https://wiki.example.com", Description: "Team wiki"}},
+	}, entries)
+}
+
+func TestImportKeepsMetadataForAdmins(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, format := range []TransferFormat{FormatJSON, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			src, s := newTransferApp()
+			s.ImportLink(ctx, store.Link{
+				Name:      "docs",
+				URL:       "https://example.com/docs",
+				Views:     42,
+				Created:   created,
+				Updated:   updated,
+				CreatedBy: "user@example.com",
+				Owners:    []string{"user@example.com", "group:engineering"},
+			}, false)
+			w := httptest.NewRecorder()
+			src.handleExport(w, httptest.NewRequest(http.MethodGet, "/api/export?format="+string(format), nil))
+			if !assert.Equal(t, http.StatusOK, w.Code) {
+				t.FailNow()
+			}
+			export := w.Body.String()
+
+			for _, admins := range [][]string{{"untracked"}, nil} {
+				dst, d := newTransferApp()
+				dst.config.Admins = admins
+				w := httptest.NewRecorder()
+				dst.handleImport(w, httptest.NewRequest(http.MethodPost, "/api/import?format="+string(format), strings.NewReader(export)))
+				if !assert.Equal(t, http.StatusOK, w.Code) {
+					t.FailNow()
+				}
+				docs, err := d.GetLinkByName(ctx, "docs")
+				if !assert.NoError(t, err) {
+					continue
+				}
+				if admins != nil {
+					assert.Equal(t, 42, docs.Views)
+					assert.True(t, created.Equal(docs.Created))
+					assert.True(t, updated.Equal(docs.Updated))
+					assert.Equal(t, "user@example.com", docs.CreatedBy)
+					assert.Equal(t, []string{"user@example.com", "group:engineering"}, docs.Owners)
+				} else {
+					// other users own what they import
+					assert.Equal(t, 0, docs.Views)
+					assert.Equal(t, "untracked", docs.CreatedBy)
+					assert.Equal(t, []string{"untracked"}, docs.Owners)
+				}
+			}
+		})
+	}
+}
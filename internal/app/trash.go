@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// maxPurgeInterval caps how long disabled links and clicks can outlive their
+// retention period.
+const maxPurgeInterval = time.Hour
+
+func (a *App) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	links, err := a.Store.GetDisabledLinks(r.Context(), identity.owners())
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	err = json.NewEncoder(w).Encode(links)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+}
+
+// handleTrashedLink restores (POST) or permanently deletes (DELETE) a link
+// from the caller's trash.
+func (a *App) handleTrashedLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		sendError(w, http.StatusMethodNotAllowed, ErrorResponse{})
+		return
+	}
+	identity, err := a.getIdentityFromRequest(r)
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusUnauthorized, ErrorResponse{Error: "missing authentication token"})
+		return
+	}
+	name, err := cleanLink(r.URL.Path[len("/api/trash/"):])
+	if err != nil {
+		sendError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	owned, err := a.Store.GetDisabledLinks(r.Context(), identity.owners())
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	var before *store.Link
+	for _, link := range owned {
+		if strings.EqualFold(link.Name, name) {
+			found := link
+			before = &found
+			name = link.Name
+			break
+		}
+	}
+	if before == nil {
+		if !a.isAdmin(identity) {
+			sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+			return
+		}
+	}
+	if r.Method == http.MethodPost {
+		err = a.Store.RestoreLink(r.Context(), name)
+	} else {
+		err = a.Store.PurgeLink(r.Context(), name)
+	}
+	if errors.Is(err, store.ErrLinkNotFound) {
+		sendError(w, http.StatusNotFound, ErrorResponse{Error: "link not found"})
+		return
+	}
+	if err != nil {
+		a.Logger.Error(err.Error())
+		sendError(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	// before is nil when an admin acts on a link outside their own trash
+	action, after := store.AuditActionPurge, (*store.Link)(nil)
+	if r.Method == http.MethodPost {
+		restored := store.Link{Name: name}
+		if before != nil {
+			restored = *before
+			restored.Disabled = false
+			restored.DisabledAt = nil
+		}
+		action, after = store.AuditActionRestore, a.currentLink(r.Context(), name, restored)
+	}
+	a.recordAudit(r, action, name, before, after)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeTrash periodically removes links that have been disabled for longer
+// than retention. It returns when ctx is cancelled.
+func (a *App) purgeTrash(ctx context.Context, retention time.Duration) {
+	interval := min(retention, maxPurgeInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		count, err := a.Store.PurgeDisabledLinks(ctx, time.Now().Add(-retention))
+		if err != nil {
+			a.Logger.Error(err.Error())
+		} else if count > 0 {
+			a.Logger.With("count", count).Info("purged disabled links")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
@@ -56,13 +57,90 @@ cCkYBl4BcxLn1UdBhgCAY5w=
 -----END PRIVATE KEY-----`
 
 type Config struct {
-	StaticPath string `env:"STATIC_PATH,default=/"`
+	StaticPath string   `env:"STATIC_PATH,default=/"`
+	AuthMode   AuthMode `env:"AUTH_MODE,default=saml"`
 	SSO        SSOConfig
-	StoreType  StoreType `env:"STORE_TYPE,default=memory"`
-	Mongo      MongoConfig
-	Postgres   PostgresConfig
-	Port       int    `env:"PORT,default=8080"`
-	FQDN       string `env:"FQDN,required"`
+	OIDC       OIDCConfig
+	Proxy      ProxyConfig
+	StoreConfig
+	Port   int      `env:"PORT,default=8080"`
+	FQDN   string   `env:"FQDN,required"`
+	Admins []string `env:"ADMINS"`
+	// AdminGroups grants admin rights to members of these groups, as reported by the auth mode.
+	AdminGroups []string `env:"ADMIN_GROUPS"`
+	// TrashRetention is how long disabled links are kept before being purged. Zero keeps them forever.
+	TrashRetention time.Duration `env:"TRASH_RETENTION,default=720h"`
+	// ViewFlushInterval enables buffering view counts in memory and writing them to the store on this interval.
+	ViewFlushInterval time.Duration `env:"VIEW_FLUSH_INTERVAL"`
+	// ClickRetention is how long link clicks are kept for stats before being purged. Zero keeps them forever.
+	ClickRetention time.Duration `env:"CLICK_RETENTION,default=2160h"`
+	// ClickFlushInterval is how often buffered clicks are written to the store. Zero writes each click on redirect.
+	ClickFlushInterval time.Duration `env:"CLICK_FLUSH_INTERVAL,default=5s"`
+	Cache              CacheConfig
+	Metrics            MetricsConfig
+	Tracing            TracingConfig
+	Server             ServerConfig
+	TLS                TLSConfig
+	Hosts              HostsConfig
+}
+
+type HostsConfig struct {
+	// Accepted hosts are served directly like FQDN instead of being redirected to it.
+	Accepted []string `env:"HOSTS"`
+	// Redirect is how requests for any other host are sent to FQDN.
+	Redirect HostRedirect `env:"HOST_REDIRECT,default=permanent"`
+	// Namespaces maps hosts to the namespace their links are looked up in first,
+	// as host=namespace pairs. These hosts are accepted as well.
+	Namespaces map[string]string `env:"HOST_NAMESPACES,separator=="`
+}
+
+type TLSConfig struct {
+	// CertFile and KeyFile serve HTTPS on PORT. They are reloaded when they change on disk.
+	CertFile string `env:"TLS_CERT_FILE"`
+	KeyFile  string `env:"TLS_KEY_FILE"`
+	// HTTPPort serves plain HTTP on this port, redirecting to https on accepted hosts and on FQDN otherwise.
+	HTTPPort int `env:"TLS_HTTP_PORT,default=0"`
+	HSTS     HSTSConfig
+}
+
+type HSTSConfig struct {
+	// MaxAge sends a Strict-Transport-Security header with HTTPS responses when set.
+	MaxAge            time.Duration `env:"HSTS_MAX_AGE"`
+	IncludeSubdomains bool          `env:"HSTS_INCLUDE_SUBDOMAINS,default=false"`
+	Preload           bool          `env:"HSTS_PRELOAD,default=false"`
+}
+
+type TracingConfig struct {
+	// Enabled exports OpenTelemetry traces with OTLP, configured by the standard OTEL_* variables.
+	Enabled bool `env:"TRACING_ENABLED,default=false"`
+}
+
+type ServerConfig struct {
+	// ReadTimeout limits how long reading a request may take, including its body.
+	ReadTimeout time.Duration `env:"SERVER_READ_TIMEOUT,default=10s"`
+	// WriteTimeout limits how long writing a response may take, such as an export.
+	WriteTimeout time.Duration `env:"SERVER_WRITE_TIMEOUT,default=1m"`
+	// IdleTimeout is how long an idle keep-alive connection is kept open.
+	IdleTimeout time.Duration `env:"SERVER_IDLE_TIMEOUT,default=2m"`
+	// DrainDelay is how long the server keeps accepting requests after SIGTERM
+	// while failing readiness checks, so load balancers stop routing to it first.
+	DrainDelay time.Duration `env:"SERVER_DRAIN_DELAY,default=5s"`
+	// ShutdownTimeout is how long in-flight requests may take to finish after the drain delay.
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT,default=20s"`
+}
+
+type MetricsConfig struct {
+	// Enabled serves Prometheus metrics at /metrics.
+	Enabled bool `env:"METRICS_ENABLED,default=false"`
+	// Port serves /metrics on a separate port instead of PORT, keeping it off the public listener.
+	Port int `env:"METRICS_PORT,default=0"`
+}
+
+type CacheConfig struct {
+	// Size enables caching link lookups for up to this many names.
+	Size        int           `env:"CACHE_SIZE,default=0"`
+	TTL         time.Duration `env:"CACHE_TTL,default=1m"`
+	NegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL,default=10s"`
 }
 
 type SSOConfig struct {
@@ -72,6 +150,64 @@ type SSOConfig struct {
 	EntityID     string `env:"SSO_ENTITY_ID"`
 	CallbackURL  string `env:"SSO_CALLBACK_URL"`
 	Require      bool   `env:"SSO_REQUIRE,default=false"`
+	// NameAttribute and GroupsAttribute name the SAML attributes used for the user's display name and group memberships.
+	NameAttribute   string `env:"SSO_NAME_ATTRIBUTE,default=displayName"`
+	GroupsAttribute string `env:"SSO_GROUPS_ATTRIBUTE,default=groups"`
+}
+
+type OIDCConfig struct {
+	Issuer       string `env:"OIDC_ISSUER"`
+	ClientID     string `env:"OIDC_CLIENT_ID"`
+	ClientSecret string `env:"OIDC_CLIENT_SECRET"`
+	// RedirectURL defaults to https://FQDN/oidc/callback.
+	RedirectURL string   `env:"OIDC_REDIRECT_URL"`
+	Scopes      []string `env:"OIDC_SCOPES,default=openid,email,profile"`
+	// EmailClaim, NameClaim and GroupsClaim name the ID token claims used for the user's email, display name and group memberships.
+	EmailClaim  string `env:"OIDC_EMAIL_CLAIM,default=email"`
+	NameClaim   string `env:"OIDC_NAME_CLAIM,default=name"`
+	GroupsClaim string `env:"OIDC_GROUPS_CLAIM,default=groups"`
+	// SessionKey signs session cookies. It must be shared by all replicas, a random key is used when it is empty.
+	SessionKey    []byte        `env:"OIDC_SESSION_KEY"`
+	SessionMaxAge time.Duration `env:"OIDC_SESSION_MAX_AGE,default=8h"`
+}
+
+type ProxyConfig struct {
+	// TrustedCIDRs are the addresses of the proxies allowed to set the identity headers.
+	TrustedCIDRs []string `env:"PROXY_TRUSTED_CIDRS"`
+	EmailHeader  string   `env:"PROXY_EMAIL_HEADER,default=X-Forwarded-Email"`
+	NameHeader   string   `env:"PROXY_NAME_HEADER,default=X-Forwarded-Preferred-Username"`
+	// GroupsHeader holds a comma separated list of groups.
+	GroupsHeader string `env:"PROXY_GROUPS_HEADER,default=X-Forwarded-Groups"`
+}
+
+type AuthMode string
+
+const (
+	AuthModeNone AuthMode = "none"
+	AuthModeSAML AuthMode = "saml"
+	AuthModeOIDC AuthMode = "oidc"
+	// AuthModeProxy trusts the identity set in headers by an authenticating reverse proxy.
+	AuthModeProxy AuthMode = "proxy"
+)
+
+type HostRedirect string
+
+const (
+	// HostRedirectPermanent redirects with 301, or 308 for methods other than GET and HEAD.
+	HostRedirectPermanent HostRedirect = "permanent"
+	// HostRedirectTemporary redirects with 302, or 307 for methods other than GET and HEAD.
+	HostRedirectTemporary HostRedirect = "temporary"
+	// HostRedirectNone serves every host directly.
+	HostRedirectNone HostRedirect = "none"
+)
+
+// StoreConfig selects and configures the backing store.
+type StoreConfig struct {
+	StoreType StoreType `env:"STORE_TYPE,default=memory"`
+	Mongo     MongoConfig
+	Postgres  PostgresConfig
+	SQLite    SQLiteConfig
+	File      FileConfig
 }
 
 type MongoConfig struct {
@@ -88,6 +224,17 @@ type PostgresConfig struct {
 	DatabaseName string `env:"POSTGRES_DB_NAME"`
 }
 
+type SQLiteConfig struct {
+	Path string `env:"SQLITE_PATH,default=links.db"`
+}
+
+type FileConfig struct {
+	Path string `env:"FILE_PATH,default=links.json"`
+	// Journal appends changes to a journal file that is compacted into Path every CompactInterval.
+	Journal         bool          `env:"FILE_JOURNAL,default=false"`
+	CompactInterval time.Duration `env:"FILE_COMPACT_INTERVAL,default=1m"`
+}
+
 type StoreType string
 
 const (
@@ -95,6 +242,7 @@ const (
 	StoreTypeFile     StoreType = "file"
 	StoreTypeMongo    StoreType = "mongo"
 	StoreTypePostgres StoreType = "postgres"
+	StoreTypeSQLite   StoreType = "sqlite"
 )
 
 func FromEnv(ctx context.Context) (Config, error) {
@@ -110,3 +258,17 @@ func FromEnv(ctx context.Context) (Config, error) {
 	}
 	return cfg, nil
 }
+
+// StoreFromEnv reads a StoreConfig from environment variables that start with
+// prefix, such as SOURCE_STORE_TYPE for the prefix "SOURCE_".
+func StoreFromEnv(ctx context.Context, prefix string) (StoreConfig, error) {
+	cfg := StoreConfig{}
+	err := envconfig.ProcessWith(ctx, &envconfig.Config{
+		Target:   &cfg,
+		Lookuper: envconfig.PrefixLookuper(prefix, envconfig.OsLookuper()),
+	})
+	if err != nil {
+		return StoreConfig{}, err
+	}
+	return cfg, nil
+}
@@ -2,29 +2,57 @@ package config
 
 import (
 	"context"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestDefaultConfig(t *testing.T) {
+	t.Setenv("FQDN", "go.example.com")
 	ctx := context.Background()
 	cfg, err := FromEnv(ctx)
 	if !assert.NoError(t, err) {
 		t.FailNow()
 	}
 	expected := Config{
-		StaticPath: "/",
-		StoreType:  StoreTypeMemory,
+		StaticPath:         "/",
+		AuthMode:           AuthModeSAML,
+		Port:               8080,
+		FQDN:               "go.example.com",
+		TrashRetention:     720 * time.Hour,
+		ClickRetention:     2160 * time.Hour,
+		ClickFlushInterval: 5 * time.Second,
+		Cache:              CacheConfig{TTL: time.Minute, NegativeTTL: 10 * time.Second},
+		Server:             ServerConfig{ReadTimeout: 10 * time.Second, WriteTimeout: time.Minute, IdleTimeout: 2 * time.Minute, DrainDelay: 5 * time.Second, ShutdownTimeout: 20 * time.Second},
+		Hosts:              HostsConfig{Redirect: HostRedirectPermanent},
+		StoreConfig: StoreConfig{
+			StoreType: StoreTypeMemory,
+			SQLite:    SQLiteConfig{Path: "links.db"},
+			File:      FileConfig{Path: "links.json", CompactInterval: time.Minute},
+		},
 		SSO: SSOConfig{
-			SamlCert:     []byte(defaultCert),
-			SamlKey:      []byte(defaultKey),
-			MetadataFile: "",
-			EntityID:     "",
-			CallbackURL:  "",
-			Require:      false,
+			SamlCert:        []byte(defaultCert),
+			SamlKey:         []byte(defaultKey),
+			MetadataFile:    "",
+			EntityID:        "",
+			CallbackURL:     "",
+			Require:         false,
+			NameAttribute:   "displayName",
+			GroupsAttribute: "groups",
+		},
+		OIDC: OIDCConfig{
+			Scopes:        []string{"openid", "email", "profile"},
+			EmailClaim:    "email",
+			NameClaim:     "name",
+			GroupsClaim:   "groups",
+			SessionMaxAge: 8 * time.Hour,
+		},
+		Proxy: ProxyConfig{
+			EmailHeader:  "X-Forwarded-Email",
+			NameHeader:   "X-Forwarded-Preferred-Username",
+			GroupsHeader: "X-Forwarded-Groups",
 		},
 	}
 	diff := cmp.Diff(cfg, expected)
@@ -34,27 +62,55 @@ func TestDefaultConfig(t *testing.T) {
 }
 
 func TestSamlConfig(t *testing.T) {
-	os.Setenv("SSO_SAML_CERT", "testCert")
-	os.Setenv("SSO_SAML_KEY", "testKey")
-	os.Setenv("SSO_METADATA_FILE", "testFile")
-	os.Setenv("SSO_ENTITY_ID", "testEntity")
-	os.Setenv("SSO_CALLBACK_URL", "testURL")
-	os.Setenv("SSO_REQUIRE", "true")
+	t.Setenv("FQDN", "go.example.com")
+	t.Setenv("SSO_SAML_CERT", "testCert")
+	t.Setenv("SSO_SAML_KEY", "testKey")
+	t.Setenv("SSO_METADATA_FILE", "testFile")
+	t.Setenv("SSO_ENTITY_ID", "testEntity")
+	t.Setenv("SSO_CALLBACK_URL", "testURL")
+	t.Setenv("SSO_REQUIRE", "true")
 	ctx := context.Background()
 	cfg, err := FromEnv(ctx)
 	if !assert.NoError(t, err) {
 		t.FailNow()
 	}
 	expected := Config{
-		StaticPath: "/",
-		StoreType:  StoreTypeMemory,
+		StaticPath:         "/",
+		AuthMode:           AuthModeSAML,
+		Port:               8080,
+		FQDN:               "go.example.com",
+		TrashRetention:     720 * time.Hour,
+		ClickRetention:     2160 * time.Hour,
+		ClickFlushInterval: 5 * time.Second,
+		Cache:              CacheConfig{TTL: time.Minute, NegativeTTL: 10 * time.Second},
+		Server:             ServerConfig{ReadTimeout: 10 * time.Second, WriteTimeout: time.Minute, IdleTimeout: 2 * time.Minute, DrainDelay: 5 * time.Second, ShutdownTimeout: 20 * time.Second},
+		Hosts:              HostsConfig{Redirect: HostRedirectPermanent},
+		StoreConfig: StoreConfig{
+			StoreType: StoreTypeMemory,
+			SQLite:    SQLiteConfig{Path: "links.db"},
+			File:      FileConfig{Path: "links.json", CompactInterval: time.Minute},
+		},
 		SSO: SSOConfig{
-			SamlCert:     []byte("testCert"),
-			SamlKey:      []byte("testKey"),
-			MetadataFile: "testFile",
-			EntityID:     "testEntity",
-			CallbackURL:  "testURL",
-			Require:      true,
+			SamlCert:        []byte("testCert"),
+			SamlKey:         []byte("testKey"),
+			MetadataFile:    "testFile",
+			EntityID:        "testEntity",
+			CallbackURL:     "testURL",
+			Require:         true,
+			NameAttribute:   "displayName",
+			GroupsAttribute: "groups",
+		},
+		OIDC: OIDCConfig{
+			Scopes:        []string{"openid", "email", "profile"},
+			EmailClaim:    "email",
+			NameClaim:     "name",
+			GroupsClaim:   "groups",
+			SessionMaxAge: 8 * time.Hour,
+		},
+		Proxy: ProxyConfig{
+			EmailHeader:  "X-Forwarded-Email",
+			NameHeader:   "X-Forwarded-Preferred-Username",
+			GroupsHeader: "X-Forwarded-Groups",
 		},
 	}
 	diff := cmp.Diff(cfg, expected)
@@ -79,6 +135,11 @@ func TestStoreTypes(t *testing.T) {
 			StoreTypeInput:    "file",
 			ExpectedStoreType: StoreTypeFile,
 		},
+		{
+			Name:              "Validate sqliteType",
+			StoreTypeInput:    "sqlite",
+			ExpectedStoreType: StoreTypeSQLite,
+		},
 		{
 			Name:              "Validate fileType",
 			StoreTypeInput:    "unknown",
@@ -86,23 +147,51 @@ func TestStoreTypes(t *testing.T) {
 		},
 	}
 
+	t.Setenv("FQDN", "go.example.com")
 	ctx := context.Background()
 	for _, tc := range cases {
-		os.Setenv("STORE_TYPE", tc.StoreTypeInput)
+		t.Setenv("STORE_TYPE", tc.StoreTypeInput)
 		cfg, err := FromEnv(ctx)
 		if !assert.NoError(t, err) {
 			t.FailNow()
 		}
 		expected := Config{
-			StaticPath: "/",
-			StoreType:  StoreType(tc.StoreTypeInput),
+			StaticPath:         "/",
+			AuthMode:           AuthModeSAML,
+			Port:               8080,
+			FQDN:               "go.example.com",
+			TrashRetention:     720 * time.Hour,
+			ClickRetention:     2160 * time.Hour,
+			ClickFlushInterval: 5 * time.Second,
+			Cache:              CacheConfig{TTL: time.Minute, NegativeTTL: 10 * time.Second},
+			Server:             ServerConfig{ReadTimeout: 10 * time.Second, WriteTimeout: time.Minute, IdleTimeout: 2 * time.Minute, DrainDelay: 5 * time.Second, ShutdownTimeout: 20 * time.Second},
+			Hosts:              HostsConfig{Redirect: HostRedirectPermanent},
+			StoreConfig: StoreConfig{
+				StoreType: StoreType(tc.StoreTypeInput),
+				SQLite:    SQLiteConfig{Path: "links.db"},
+				File:      FileConfig{Path: "links.json", CompactInterval: time.Minute},
+			},
 			SSO: SSOConfig{
-				SamlCert:     []byte(defaultCert),
-				SamlKey:      []byte(defaultKey),
-				MetadataFile: "",
-				EntityID:     "",
-				CallbackURL:  "",
-				Require:      false,
+				SamlCert:        []byte(defaultCert),
+				SamlKey:         []byte(defaultKey),
+				MetadataFile:    "",
+				EntityID:        "",
+				CallbackURL:     "",
+				Require:         false,
+				NameAttribute:   "displayName",
+				GroupsAttribute: "groups",
+			},
+			OIDC: OIDCConfig{
+				Scopes:        []string{"openid", "email", "profile"},
+				EmailClaim:    "email",
+				NameClaim:     "name",
+				GroupsClaim:   "groups",
+				SessionMaxAge: 8 * time.Hour,
+			},
+			Proxy: ProxyConfig{
+				EmailHeader:  "X-Forwarded-Email",
+				NameHeader:   "X-Forwarded-Preferred-Username",
+				GroupsHeader: "X-Forwarded-Groups",
 			},
 		}
 		diff := cmp.Diff(cfg, expected)
@@ -112,3 +201,62 @@ func TestStoreTypes(t *testing.T) {
 	}
 
 }
+
+func TestStoreFromEnv(t *testing.T) {
+	t.Setenv("STORE_TYPE", "memory")
+	t.Setenv("SOURCE_STORE_TYPE", "sqlite")
+	t.Setenv("SOURCE_SQLITE_PATH", "/data/links.db")
+	t.Setenv("DEST_STORE_TYPE", "postgres")
+	t.Setenv("DEST_POSTGRES_HOST", "postgres.postgres")
+	ctx := context.Background()
+
+	source, err := StoreFromEnv(ctx, "SOURCE_")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected := StoreConfig{
+		StoreType: StoreTypeSQLite,
+		SQLite:    SQLiteConfig{Path: "/data/links.db"},
+		File:      FileConfig{Path: "links.json", CompactInterval: time.Minute},
+	}
+	diff := cmp.Diff(source, expected)
+	if !assert.Equal(t, "", diff) {
+		t.Fail()
+	}
+
+	dest, err := StoreFromEnv(ctx, "DEST_")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected = StoreConfig{
+		StoreType: StoreTypePostgres,
+		Postgres:  PostgresConfig{Host: "postgres.postgres"},
+		SQLite:    SQLiteConfig{Path: "links.db"},
+		File:      FileConfig{Path: "links.json", CompactInterval: time.Minute},
+	}
+	diff = cmp.Diff(dest, expected)
+	if !assert.Equal(t, "", diff) {
+		t.Fail()
+	}
+}
+
+func TestHostsConfig(t *testing.T) {
+	t.Setenv("FQDN", "go.example.com")
+	t.Setenv("HOSTS", "go.corp,[fd00::1]")
+	t.Setenv("HOST_REDIRECT", "temporary")
+	t.Setenv("HOST_NAMESPACES", "go.internal=internal,[fd00::2]:8080=lab")
+	ctx := context.Background()
+	cfg, err := FromEnv(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected := HostsConfig{
+		Accepted:   []string{"go.corp", "[fd00::1]"},
+		Redirect:   HostRedirectTemporary,
+		Namespaces: map[string]string{"go.internal": "internal", "[fd00::2]:8080": "lab"},
+	}
+	diff := cmp.Diff(cfg.Hosts, expected)
+	if !assert.Equal(t, "", diff) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/imdevinc/go-links/internal/store"
+)
+
+// ConflictPolicy decides what happens when a link already exists in the destination.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictFail      ConflictPolicy = "fail"
+)
+
+var ErrConflict = errors.New("link exists in destination")
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch ConflictPolicy(s) {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return ConflictPolicy(s), nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q", s)
+}
+
+type Options struct {
+	Conflict ConflictPolicy
+	DryRun   bool
+}
+
+// Report counts what happened to each link read from the source.
+type Report struct {
+	Read        int `json:"read"`
+	Created     int `json:"created"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+}
+
+// Run copies every link, including disabled ones, from src to dst keeping
+// views, timestamps and owners. In dry-run mode nothing is written but the
+// report reflects what would have happened. With ConflictFail the source is
+// checked for conflicts before any link is written.
+func Run(ctx context.Context, src store.Store, dst store.Store, opts Options) (Report, error) {
+	// names are compared case-insensitively, like the stores do
+	existing := map[string]struct{}{}
+	err := dst.WalkLinks(ctx, func(link store.Link) error {
+		existing[strings.ToLower(link.Name)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to list destination links: %w", err)
+	}
+
+	if opts.Conflict == ConflictFail {
+		// checked before anything is written so a conflict leaves the
+		// destination untouched
+		err = src.WalkLinks(ctx, func(link store.Link) error {
+			if _, exists := existing[strings.ToLower(link.Name)]; exists {
+				return fmt.Errorf("%w: %s", ErrConflict, link.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			return Report{}, err
+		}
+	}
+
+	report := Report{}
+	err = src.WalkLinks(ctx, func(link store.Link) error {
+		report.Read++
+		_, exists := existing[strings.ToLower(link.Name)]
+		if exists && opts.Conflict == ConflictSkip {
+			report.Skipped++
+			return nil
+		}
+		if exists && opts.Conflict == ConflictFail {
+			return fmt.Errorf("%w: %s", ErrConflict, link.Name)
+		}
+		if !opts.DryRun {
+			err := dst.ImportLink(ctx, link, opts.Conflict == ConflictOverwrite)
+			if errors.Is(err, store.ErrIDExists) {
+				// created in the destination after it was listed
+				if opts.Conflict == ConflictFail {
+					return fmt.Errorf("%w: %s", ErrConflict, link.Name)
+				}
+				report.Skipped++
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", link.Name, err)
+			}
+		}
+		if exists {
+			report.Overwritten++
+		} else {
+			report.Created++
+		}
+		return nil
+	})
+	return report, err
+}
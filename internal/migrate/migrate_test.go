@@ -0,0 +1,99 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/imdevinc/go-links/internal/migrate"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	disabledAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sourceLinks := []store.Link{
+		{Name: "docs", URL: "https://example.com/docs", Views: 42, Created: created, Updated: created, CreatedBy: "user@example.com", Owners: []string{"user@example.com", "group:engineering"}},
+		{Name: "oncall", URL: "https://example.com/oncall", Views: 7, Created: created, Updated: created, CreatedBy: "other@example.com", Owners: []string{"other@example.com"}},
+		{Name: "old", URL: "https://example.com/old", Created: created, Updated: created, CreatedBy: "user@example.com", Owners: []string{"user@example.com"}, Disabled: true, DisabledAt: &disabledAt},
+	}
+	// the destination has the link under another case
+	existing := store.Link{Name: "DOCS", URL: "https://example.com/existing", Views: 1}
+
+	cases := []struct {
+		Name           string
+		Options        migrate.Options
+		ExpectedReport migrate.Report
+		ExpectedErr    error
+		ExpectedDocs   store.Link
+	}{
+		{
+			Name:           "Skip conflicts",
+			Options:        migrate.Options{Conflict: migrate.ConflictSkip},
+			ExpectedReport: migrate.Report{Read: 3, Created: 2, Skipped: 1},
+			ExpectedDocs:   existing,
+		},
+		{
+			Name:           "Overwrite conflicts",
+			Options:        migrate.Options{Conflict: migrate.ConflictOverwrite},
+			ExpectedReport: migrate.Report{Read: 3, Created: 2, Overwritten: 1},
+			ExpectedDocs:   sourceLinks[0],
+		},
+		{
+			Name:           "Dry run",
+			Options:        migrate.Options{Conflict: migrate.ConflictOverwrite, DryRun: true},
+			ExpectedReport: migrate.Report{Read: 3, Created: 2, Overwritten: 1},
+			ExpectedDocs:   existing,
+		},
+		{
+			Name:        "Fail on conflict",
+			Options:     migrate.Options{Conflict: migrate.ConflictFail},
+			ExpectedErr: migrate.ErrConflict,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			src := store.NewMemoryStore()
+			for _, link := range sourceLinks {
+				src.ImportLink(ctx, link, false)
+			}
+			dst := store.NewMemoryStore()
+			dst.ImportLink(ctx, existing, false)
+
+			report, err := migrate.Run(ctx, src, dst, tc.Options)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+				// nothing is written, whichever link conflicts
+				assert.Equal(t, migrate.Report{}, report)
+				walked := 0
+				dst.WalkLinks(ctx, func(store.Link) error {
+					walked++
+					return nil
+				})
+				assert.Equal(t, 1, walked)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.ExpectedReport, report)
+
+			docs, _ := dst.GetLinkByName(ctx, "docs")
+			assert.Equal(t, "", cmp.Diff(tc.ExpectedDocs, docs))
+			if tc.Options.DryRun {
+				_, err := dst.GetLinkByName(ctx, "oncall")
+				assert.ErrorIs(t, err, store.ErrLinkNotFound)
+				return
+			}
+			oncall, _ := dst.GetLinkByName(ctx, "oncall")
+			assert.Equal(t, "", cmp.Diff(sourceLinks[1], oncall))
+			assert.ErrorIs(t, dst.CreateLink(ctx, store.Link{Name: "old"}), store.ErrIDExists)
+			trash, _ := dst.GetDisabledLinks(ctx, []string{"user@example.com"})
+			assert.Equal(t, "", cmp.Diff([]store.Link{sourceLinks[2]}, trash))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Click is a single redirect through a link.
+type Click struct {
+	Link      string    `json:"link" bson:"link"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	Referrer  string    `json:"referrer,omitempty" bson:"referrer,omitempty"`
+	User      string    `json:"user,omitempty" bson:"user,omitempty"`
+}
+
+// ClickBucket aggregates the clicks for a link over one interval starting at Start.
+type ClickBucket struct {
+	Start       time.Time `json:"start"`
+	Clicks      int       `json:"clicks"`
+	UniqueUsers int       `json:"unique_users"`
+}
+
+type StatsInterval string
+
+const (
+	StatsIntervalDay  StatsInterval = "day"
+	StatsIntervalWeek StatsInterval = "week"
+)
+
+func ParseStatsInterval(s string) (StatsInterval, error) {
+	switch StatsInterval(s) {
+	case StatsIntervalDay, StatsIntervalWeek:
+		return StatsInterval(s), nil
+	}
+	return "", fmt.Errorf("unknown stats interval %q", s)
+}
+
+// Truncate returns the start of the interval containing t, in UTC. Weeks start on Monday.
+func (i StatsInterval) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if i == StatsIntervalWeek {
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// Add moves start forward by n intervals, or backwards when n is negative.
+func (i StatsInterval) Add(start time.Time, n int) time.Time {
+	if i == StatsIntervalWeek {
+		return start.AddDate(0, 0, 7*n)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+// Analytics records link clicks and reports them as a time series.
+type Analytics interface {
+	RecordClick(ctx context.Context, click Click) error
+	// RecordClicks stores a batch of clicks, such as those buffered by NewBufferedClicks.
+	RecordClicks(ctx context.Context, clicks []Click) error
+	// GetClickStats returns the non-empty buckets for the link since the given time, oldest first.
+	GetClickStats(ctx context.Context, name string, interval StatsInterval, since time.Time) ([]ClickBucket, error)
+	// PurgeClicks removes clicks recorded before the given time and returns how many were removed.
+	PurgeClicks(ctx context.Context, before time.Time) (int, error)
+}
+
+// bucketClicks aggregates clicks for stores that cannot do so natively.
+func bucketClicks(clicks []Click, name string, interval StatsInterval, since time.Time) []ClickBucket {
+	buckets := map[time.Time]*ClickBucket{}
+	users := map[time.Time]map[string]struct{}{}
+	for _, click := range clicks {
+		if click.Link != name || click.Timestamp.Before(since) {
+			continue
+		}
+		start := interval.Truncate(click.Timestamp)
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = &ClickBucket{Start: start}
+			buckets[start] = bucket
+			users[start] = map[string]struct{}{}
+		}
+		bucket.Clicks++
+		if click.User != "" {
+			users[start][click.User] = struct{}{}
+		}
+	}
+	result := make([]ClickBucket, 0, len(buckets))
+	for start, bucket := range buckets {
+		bucket.UniqueUsers = len(users[start])
+		result = append(result, *bucket)
+	}
+	slices.SortFunc(result, func(a, b ClickBucket) int {
+		return a.Start.Compare(b.Start)
+	})
+	return result
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AuditAction is the kind of change an AuditEvent records.
+type AuditAction string
+
+const auditColumns = "id, occurred_at, actor, action, link, link_before, link_after, client_ip, admin_override"
+
+const (
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDisable AuditAction = "disable"
+	AuditActionRestore AuditAction = "restore"
+	AuditActionPurge   AuditAction = "purge"
+	AuditActionOwners  AuditAction = "owners"
+)
+
+// AuditEvent records a change to a link. Before is nil for new links and
+// After is nil for purged links.
+type AuditEvent struct {
+	ID       string      `json:"id" bson:"_id"`
+	Time     time.Time   `json:"time" bson:"time"`
+	Actor    string      `json:"actor" bson:"actor"`
+	Action   AuditAction `json:"action" bson:"action"`
+	Link     string      `json:"link" bson:"link"`
+	Before   *Link       `json:"before,omitempty" bson:"before,omitempty"`
+	After    *Link       `json:"after,omitempty" bson:"after,omitempty"`
+	ClientIP string      `json:"client_ip,omitempty" bson:"client_ip,omitempty"`
+	// AdminOverride is set when an admin changed a link they do not own.
+	AdminOverride bool `json:"admin_override,omitempty" bson:"admin_override,omitempty"`
+}
+
+// AuditFilter selects audit events. Empty fields match every event.
+type AuditFilter struct {
+	Link  string
+	Actor string
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+func (f AuditFilter) matches(event AuditEvent) bool {
+	return (f.Link == "" || strings.EqualFold(event.Link, f.Link)) &&
+		(f.Actor == "" || strings.EqualFold(event.Actor, f.Actor)) &&
+		(f.Since.IsZero() || !event.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || event.Time.Before(f.Until))
+}
+
+// Audit is an append-only log of link changes. Events can not be changed or
+// removed once recorded.
+type Audit interface {
+	RecordAuditEvent(ctx context.Context, event AuditEvent) error
+	// GetAuditEvents returns the events matching filter, newest first.
+	GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+// filterAuditEvents returns the events matching filter, newest first.
+func filterAuditEvents(events []AuditEvent, filter AuditFilter) []AuditEvent {
+	matched := []AuditEvent{}
+	for _, event := range events {
+		if filter.matches(event) {
+			matched = append(matched, event)
+		}
+	}
+	slices.SortStableFunc(matched, func(a AuditEvent, b AuditEvent) int {
+		return b.Time.Compare(a.Time)
+	})
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+	return matched
+}
+
+// auditQuery returns the SQL query and arguments for the events matching
+// filter. equalFold formats a case-insensitive comparison of a column with a
+// numbered parameter.
+func auditQuery(filter AuditFilter, equalFold string) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Link != "" {
+		add(fmt.Sprintf(equalFold, "link", "%d"), filter.Link)
+	}
+	if filter.Actor != "" {
+		add(fmt.Sprintf(equalFold, "actor", "%d"), filter.Actor)
+	}
+	if !filter.Since.IsZero() {
+		add("occurred_at >= $%d", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		add("occurred_at < $%d", filter.Until.UTC())
+	}
+	query := "select " + auditColumns + " from audit_events"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by occurred_at desc"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" limit $%d", len(args))
+	}
+	return query, args
+}
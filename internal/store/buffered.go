@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// bufferedViews wraps a Store so that view increments are counted in memory
+// and written to the underlying store as aggregated deltas on an interval and
+// on Close. Redirects therefore never wait on the view count write.
+type bufferedViews struct {
+	Store
+	logger    *slog.Logger
+	mu        sync.Mutex
+	pending   map[string]int
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ Store = (*bufferedViews)(nil)
+
+func NewBufferedViewStore(s Store, interval time.Duration, logger *slog.Logger) *bufferedViews {
+	b := &bufferedViews{
+		Store:   s,
+		logger:  logger,
+		pending: map[string]int{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+func (b *bufferedViews) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			if err := b.Flush(context.Background()); err != nil {
+				b.logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+// IncrementLinkViews implements Store.
+func (b *bufferedViews) IncrementLinkViews(ctx context.Context, name string) error {
+	return b.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store.
+func (b *bufferedViews) AddLinkViews(ctx context.Context, name string, delta int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[name] += delta
+	return nil
+}
+
+// Flush writes all pending view counts to the underlying store. Deltas that
+// fail to write are kept for the next flush, except for links that no longer
+// exist.
+func (b *bufferedViews) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = map[string]int{}
+	b.mu.Unlock()
+
+	var errs []error
+	for name, delta := range pending {
+		err := b.Store.AddLinkViews(ctx, name, delta)
+		if err == nil || errors.Is(err, ErrLinkNotFound) {
+			continue
+		}
+		errs = append(errs, err)
+		b.AddLinkViews(ctx, name, delta)
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements Store. It flushes pending view counts before closing the
+// underlying store.
+func (b *bufferedViews) Close(ctx context.Context) error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		err = errors.Join(b.Flush(ctx), b.Store.Close(ctx))
+	})
+	return err
+}
+
+// maxPendingClicks caps how many clicks bufferedClicks holds between flushes.
+// Clicks past the cap are dropped rather than letting a slow or unavailable
+// store grow memory without bound.
+const maxPendingClicks = 10_000
+
+// bufferedClicks wraps an Analytics store so that clicks are held in memory
+// and written to the underlying store in batches on an interval and on Close.
+// Redirects therefore never wait on the click write.
+type bufferedClicks struct {
+	Analytics
+	logger    *slog.Logger
+	mu        sync.Mutex
+	pending   []Click
+	dropped   int
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ Analytics = (*bufferedClicks)(nil)
+
+func NewBufferedClicks(a Analytics, interval time.Duration, logger *slog.Logger) *bufferedClicks {
+	b := &bufferedClicks{
+		Analytics: a,
+		logger:    logger,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+func (b *bufferedClicks) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			if err := b.Flush(context.Background()); err != nil {
+				b.logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+// RecordClick implements Analytics.
+func (b *bufferedClicks) RecordClick(ctx context.Context, click Click) error {
+	return b.RecordClicks(ctx, []Click{click})
+}
+
+// RecordClicks implements Analytics.
+func (b *bufferedClicks) RecordClicks(ctx context.Context, clicks []Click) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room := max(maxPendingClicks-len(b.pending), 0)
+	if len(clicks) > room {
+		b.dropped += len(clicks) - room
+		clicks = clicks[:room]
+	}
+	b.pending = append(b.pending, clicks...)
+	return nil
+}
+
+// Flush writes all pending clicks to the underlying store. Clicks that fail
+// to write are kept for the next flush, up to maxPendingClicks.
+func (b *bufferedClicks) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	pending := b.pending
+	dropped := b.dropped
+	b.pending = nil
+	b.dropped = 0
+	b.mu.Unlock()
+
+	if dropped > 0 {
+		b.logger.With("count", dropped).Warn("dropped clicks, too many were waiting to be written")
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	err := b.Analytics.RecordClicks(ctx, pending)
+	if err != nil {
+		b.RecordClicks(ctx, pending)
+	}
+	return err
+}
+
+// Close stops the flush interval and writes any pending clicks. The
+// underlying store is not closed.
+func (b *bufferedClicks) Close(ctx context.Context) error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		err = b.Flush(ctx)
+	})
+	return err
+}
@@ -0,0 +1,78 @@
+package store_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedViewStore(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	m.CreateLink(ctx, store.Link{Name: "test"})
+	b := store.NewBufferedViewStore(m, time.Hour, slog.Default())
+
+	var wg sync.WaitGroup
+	for range [50]int{} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.IncrementLinkViews(ctx, "test")
+		}()
+	}
+	b.IncrementLinkViews(ctx, "missing")
+	wg.Wait()
+
+	link, _ := m.GetLinkByName(ctx, "test")
+	assert.Equal(t, 0, link.Views)
+
+	if !assert.NoError(t, b.Flush(ctx)) {
+		t.FailNow()
+	}
+	link, _ = m.GetLinkByName(ctx, "test")
+	assert.Equal(t, 50, link.Views)
+
+	b.IncrementLinkViews(ctx, "test")
+	if !assert.NoError(t, b.Close(ctx)) {
+		t.FailNow()
+	}
+	link, _ = m.GetLinkByName(ctx, "test")
+	assert.Equal(t, 51, link.Views)
+}
+
+func TestBufferedClicks(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	b := store.NewBufferedClicks(m, time.Hour, slog.Default())
+	now := time.Now()
+	for range [3]int{} {
+		assert.NoError(t, b.RecordClick(ctx, store.Click{Link: "test", Timestamp: now}))
+	}
+
+	total := func() int {
+		daily, err := m.GetClickStats(ctx, "test", store.StatsIntervalDay, time.Time{})
+		assert.NoError(t, err)
+		total := 0
+		for _, bucket := range daily {
+			total += bucket.Clicks
+		}
+		return total
+	}
+	assert.Equal(t, 0, total())
+
+	if !assert.NoError(t, b.Flush(ctx)) {
+		t.FailNow()
+	}
+	assert.Equal(t, 3, total())
+
+	b.RecordClick(ctx, store.Click{Link: "test", Timestamp: now})
+	if !assert.NoError(t, b.Close(ctx)) {
+		t.FailNow()
+	}
+	assert.Equal(t, 4, total())
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheOptions configures a Cached store.
+type CacheOptions struct {
+	// Size is the maximum number of names kept, found or not.
+	Size int
+	// TTL is how long a found link is served from the cache.
+	TTL time.Duration
+	// NegativeTTL is how long a name that was not found is remembered. Zero
+	// disables negative caching.
+	NegativeTTL time.Duration
+}
+
+// CacheStats are the counters of a Cached store since it was created.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+// HitRate is the share of lookups served from the cache, between 0 and 1.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type cacheEntry struct {
+	key     string
+	link    Link
+	found   bool
+	expires time.Time
+}
+
+// Cached wraps a Store with a bounded least recently used cache for
+// GetLinkByName, which is called on every redirect. Links are removed from the
+// cache when they are changed through the Cached store or reported to Follow.
+// Otherwise changes made by other replicas are only seen once an entry expires.
+type Cached struct {
+	Store
+	opts    CacheOptions
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	// generation is bumped on every invalidation so lookups that started
+	// before it do not store what may be an outdated link.
+	generation uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+var _ Store = (*Cached)(nil)
+
+func NewCachedStore(s Store, opts CacheOptions) *Cached {
+	return &Cached{
+		Store:   s,
+		opts:    opts,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func cacheKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// Stats returns the cache counters.
+func (c *Cached) Stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+// GetLinkByName implements Store.
+func (c *Cached) GetLinkByName(ctx context.Context, name string) (Link, error) {
+	key := cacheKey(name)
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			if !entry.found {
+				return Link{}, ErrLinkNotFound
+			}
+			return entry.link, nil
+		}
+		c.remove(element)
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	link, err := c.Store.GetLinkByName(ctx, name)
+	switch {
+	case err == nil:
+		c.add(generation, &cacheEntry{key: key, link: link, found: true, expires: time.Now().Add(c.opts.TTL)})
+	case err == ErrLinkNotFound && c.opts.NegativeTTL > 0:
+		c.add(generation, &cacheEntry{key: key, expires: time.Now().Add(c.opts.NegativeTTL)})
+	}
+	return link, err
+}
+
+func (c *Cached) add(generation uint64, entry *cacheEntry) {
+	if c.opts.Size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for len(c.entries) > c.opts.Size {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove must be called with c.mu held.
+func (c *Cached) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// invalidate drops name from the cache.
+func (c *Cached) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if element, ok := c.entries[cacheKey(name)]; ok {
+		c.remove(element)
+	}
+}
+
+// Follow drops links from the cache as changes arrive, typically from another
+// replica through Subscriber, until changes is closed.
+func (c *Cached) Follow(changes <-chan LinkChange) {
+	for change := range changes {
+		if change.Name == "" {
+			c.clear()
+			continue
+		}
+		c.invalidate(change.Name)
+	}
+}
+
+func (c *Cached) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// CreateLink implements Store.
+func (c *Cached) CreateLink(ctx context.Context, link Link) error {
+	defer c.invalidate(link.Name)
+	return c.Store.CreateLink(ctx, link)
+}
+
+// UpdateLink implements Store.
+func (c *Cached) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	defer c.invalidate(name)
+	return c.Store.UpdateLink(ctx, name, patch)
+}
+
+// DisableLink implements Store.
+func (c *Cached) DisableLink(ctx context.Context, name string) error {
+	defer c.invalidate(name)
+	return c.Store.DisableLink(ctx, name)
+}
+
+// RestoreLink implements Store.
+func (c *Cached) RestoreLink(ctx context.Context, name string) error {
+	defer c.invalidate(name)
+	return c.Store.RestoreLink(ctx, name)
+}
+
+// PurgeLink implements Store.
+func (c *Cached) PurgeLink(ctx context.Context, name string) error {
+	defer c.invalidate(name)
+	return c.Store.PurgeLink(ctx, name)
+}
+
+// IncrementLinkViews implements Store.
+func (c *Cached) IncrementLinkViews(ctx context.Context, name string) error {
+	return c.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store. A cached link has its views updated in place
+// rather than being dropped, as this is called on every redirect.
+func (c *Cached) AddLinkViews(ctx context.Context, name string, delta int) error {
+	err := c.Store.AddLinkViews(ctx, name, delta)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[cacheKey(name)]; ok {
+		element.Value.(*cacheEntry).link.Views += delta
+	}
+	return nil
+}
+
+// ImportLink implements Store.
+func (c *Cached) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	defer c.invalidate(link.Name)
+	return c.Store.ImportLink(ctx, link, overwrite)
+}
@@ -0,0 +1,132 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/imdevinc/go-links/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingStore struct {
+	store.Store
+	lookups int
+}
+
+func (c *countingStore) GetLinkByName(ctx context.Context, name string) (store.Link, error) {
+	c.lookups++
+	return c.Store.GetLinkByName(ctx, name)
+}
+
+func TestCachedConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewCachedStore(store.NewMemoryStore(), store.CacheOptions{Size: 100, TTL: time.Minute, NegativeTTL: time.Minute})
+	})
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingStore{Store: store.NewMemoryStore()}
+	s := store.NewCachedStore(backing, store.CacheOptions{Size: 2, TTL: time.Minute, NegativeTTL: time.Minute})
+
+	_, err := s.GetLinkByName(ctx, "docs")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	_, err = s.GetLinkByName(ctx, "docs")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	assert.Equal(t, 1, backing.lookups)
+
+	// creating the link drops the cached miss
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"}))
+	link, err := s.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/docs", link.URL)
+	}
+	link, err = s.GetLinkByName(ctx, "DOCS")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/docs", link.URL)
+	}
+	assert.Equal(t, 2, backing.lookups)
+
+	assert.NoError(t, s.IncrementLinkViews(ctx, "docs"))
+	link, _ = s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, 1, link.Views)
+	assert.Equal(t, 2, backing.lookups)
+
+	url := "https://example.com/wiki"
+	assert.NoError(t, s.UpdateLink(ctx, "docs", store.LinkPatch{URL: &url}))
+	link, _ = s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, url, link.URL)
+	assert.Equal(t, 3, backing.lookups)
+
+	assert.NoError(t, s.DisableLink(ctx, "docs"))
+	_, err = s.GetLinkByName(ctx, "docs")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	assert.Equal(t, 4, backing.lookups)
+
+	// only the two most recently used names are kept, so docs is evicted by
+	// wiki and then evicts oncall
+	s.GetLinkByName(ctx, "oncall")
+	s.GetLinkByName(ctx, "wiki")
+	s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, 7, backing.lookups)
+
+	stats := s.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(7), stats.Misses)
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+	assert.InDelta(t, 0.3, stats.HitRate(), 0.001)
+}
+
+func TestCachedStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingStore{Store: store.NewMemoryStore()}
+	s := store.NewCachedStore(backing, store.CacheOptions{Size: 10, TTL: 20 * time.Millisecond})
+	assert.NoError(t, backing.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"}))
+
+	s.GetLinkByName(ctx, "docs")
+	s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, 1, backing.lookups)
+	time.Sleep(30 * time.Millisecond)
+	s.GetLinkByName(ctx, "docs")
+	assert.Equal(t, 2, backing.lookups)
+
+	// misses are not cached without a negative TTL
+	s.GetLinkByName(ctx, "wiki")
+	s.GetLinkByName(ctx, "wiki")
+	assert.Equal(t, 4, backing.lookups)
+}
+
+func TestCachedStoreFollow(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingStore{Store: store.NewMemoryStore()}
+	s := store.NewCachedStore(backing, store.CacheOptions{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+	assert.NoError(t, backing.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"}))
+	s.GetLinkByName(ctx, "docs")
+	s.GetLinkByName(ctx, "wiki")
+
+	changes := make(chan store.LinkChange)
+	done := make(chan struct{})
+	go func() {
+		s.Follow(changes)
+		close(done)
+	}()
+
+	// a change made by another replica
+	url := "https://example.com/other"
+	assert.NoError(t, backing.UpdateLink(ctx, "docs", store.LinkPatch{URL: &url}))
+	changes <- store.LinkChange{Name: "DOCS", Op: store.ChangeUpdated}
+	changes <- store.LinkChange{}
+	close(changes)
+	<-done
+
+	link, err := s.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, url, link.URL)
+	}
+	s.GetLinkByName(ctx, "wiki")
+	assert.Equal(t, 4, backing.lookups)
+	assert.Equal(t, 2, s.Stats().Entries)
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+type ChangeOp string
+
+const (
+	ChangeCreated ChangeOp = "create"
+	ChangeUpdated ChangeOp = "update"
+	ChangeDeleted ChangeOp = "delete"
+)
+
+// LinkChange is a change to a link made by any process using the same store.
+// A LinkChange without a Name means changes may have been missed, for example
+// while reconnecting, and anything derived from the store should be reloaded.
+type LinkChange struct {
+	Name string   `json:"name,omitempty"`
+	Op   ChangeOp `json:"op,omitempty"`
+}
+
+// Subscriber is implemented by stores shared between replicas that can report
+// changes made by any of them.
+type Subscriber interface {
+	// Subscribe returns a channel of changes to links, including disabling and
+	// restoring them but not view counts. The channel is closed once ctx is done.
+	Subscribe(ctx context.Context) (<-chan LinkChange, error)
+}
+
+// subscriptionRetryDelay is how long a subscription waits before reconnecting
+// after losing its connection.
+const subscriptionRetryDelay = 5 * time.Second
+
+// sendChange delivers change unless ctx is done first.
+func sendChange(ctx context.Context, changes chan<- LinkChange, change LinkChange) bool {
+	select {
+	case changes <- change:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// waitToRetry waits before reconnecting and reports whether ctx is still active.
+func waitToRetry(ctx context.Context) bool {
+	timer := time.NewTimer(subscriptionRetryDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,137 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/imdevinc/go-links/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore()
+	})
+}
+
+func TestFileConformance(t *testing.T) {
+	for _, journal := range []bool{false, true} {
+		storetest.Run(t, func(t *testing.T) store.Store {
+			ctx := context.Background()
+			s, err := store.NewFileStore(filepath.Join(t.TempDir(), "links.json"), true, store.FileOptions{Journal: journal})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			t.Cleanup(func() { s.Close(ctx) })
+			return s
+		})
+	}
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		ctx := context.Background()
+		s, err := store.NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "links.db"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() { s.Close(ctx) })
+		return s
+	})
+}
+
+// The postgres and mongo stores are tested against the servers configured with
+// the usual store settings prefixed with TEST_, such as TEST_POSTGRES_HOST, and
+// skipped when they are not set. Their existing links are removed.
+
+func TestPostgresConformance(t *testing.T) {
+	ctx := context.Background()
+	cfg := testStoreConfig(t)
+	if cfg.Postgres.Host == "" {
+		t.Skip("TEST_POSTGRES_HOST is not set")
+	}
+	exporter := traceDrivers(t)
+	s, err := store.NewPostgresStore(ctx, cfg.Postgres.Username, cfg.Postgres.Password, cfg.Postgres.Host, cfg.Postgres.DatabaseName)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close(ctx)
+	storetest.Run(t, reuseStore(s))
+	testSubscribe(t, s)
+	assertDriverSpans(t, exporter, "postgresql")
+}
+
+func TestMongoConformance(t *testing.T) {
+	ctx := context.Background()
+	cfg := testStoreConfig(t)
+	if cfg.Mongo.Host == "" {
+		t.Skip("TEST_MONGO_HOST is not set")
+	}
+	exporter := traceDrivers(t)
+	s, err := store.NewMongoDBStore(ctx, cfg.Mongo.Username, cfg.Mongo.Password, cfg.Mongo.Host, cfg.Mongo.DatabaseName)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close(ctx)
+	storetest.Run(t, reuseStore(s))
+	testSubscribe(t, s)
+	assertDriverSpans(t, exporter, "mongodb")
+}
+
+func testStoreConfig(t *testing.T) config.StoreConfig {
+	cfg, err := config.StoreFromEnv(context.Background(), "TEST_")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return cfg
+}
+
+func reuseStore(s store.Store) func(t *testing.T) store.Store {
+	return func(t *testing.T) store.Store {
+		if !assert.NoError(t, storetest.Clear(context.Background(), s)) {
+			t.FailNow()
+		}
+		return s
+	}
+}
+
+func testSubscribe(t *testing.T, s interface {
+	store.Store
+	store.Subscriber
+}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !assert.NoError(t, storetest.Clear(ctx, s)) {
+		t.FailNow()
+	}
+	changes, err := s.Subscribe(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"}))
+	assert.NoError(t, s.IncrementLinkViews(ctx, "docs"))
+	assert.NoError(t, s.DisableLink(ctx, "docs"))
+	assert.NoError(t, s.PurgeLink(ctx, "docs"))
+
+	expected := []store.LinkChange{
+		{Name: "docs", Op: store.ChangeCreated},
+		{Name: "docs", Op: store.ChangeUpdated},
+		{Name: "docs", Op: store.ChangeDeleted},
+	}
+	for _, change := range expected {
+		select {
+		case received := <-changes:
+			assert.Equal(t, change, received)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", change)
+		}
+	}
+	cancel()
+	for range changes {
+	}
+}
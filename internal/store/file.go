@@ -1,95 +1,600 @@
 package store
 
 import (
+	"bufio"
+	"bytes"
 	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
+type journalOp string
+
+const (
+	journalPut    journalOp = "put"
+	journalDelete journalOp = "delete"
+)
+
+// journalEntry is a single change appended to the journal file.
+type journalEntry struct {
+	Op   journalOp `json:"op"`
+	Name string    `json:"name"`
+	Link *Link     `json:"link,omitempty"`
+}
+
+// FileOptions configures the optional behavior of the file store.
+type FileOptions struct {
+	// Journal appends every change to a journal next to the links file
+	// instead of rewriting the whole file on each change.
+	Journal bool
+	// CompactInterval is how often the journal is folded back into the links
+	// file and the links file is checked for changes made outside the
+	// service. Zero disables both.
+	CompactInterval time.Duration
+	// ReadOnly reads the links file and journal without compacting them, on
+	// open or on Close. Changes must not be made to a read-only store.
+	ReadOnly bool
+	Logger   *slog.Logger
+}
+
 type file struct {
-	path  string
-	links map[string]Link
-	mu    sync.Mutex
+	path        string
+	clicksPath  string
+	tokensPath  string
+	auditPath   string
+	historyPath string
+	journalPath string
+	links       map[string]Link
+	mu          sync.RWMutex
+	clicksMu    sync.Mutex
+	tokensMu    sync.Mutex
+	auditMu     sync.Mutex
+	historyMu   sync.Mutex
+	// clicks are the contents of the clicks file, read once on open so stats
+	// are served without reading the file again.
+	clicks []Click
+	// auditIDs are the IDs of the events in the audit file, read once on open
+	// so duplicates are found without reading the file again.
+	auditIDs map[string]bool
+	// revisionCounts are the number of revisions of each link, by lowercased
+	// name, read once on open so new revisions are numbered without reading
+	// the history file again.
+	revisionCounts map[string]int
+	opts           FileOptions
+	journal        *os.File
+	// snapshot is the state of the links file when it was last read or
+	// written, used to detect changes made outside the service.
+	snapshot  os.FileInfo
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 var _ Store = (*file)(nil)
+var _ Analytics = (*file)(nil)
+var _ Tokens = (*file)(nil)
+var _ Audit = (*file)(nil)
+var _ Revisions = (*file)(nil)
 
-func NewFileStore(path string, createFile bool) (*file, error) {
-	flags := os.O_RDWR
-	if createFile {
-		flags = os.O_RDWR | os.O_CREATE | os.O_APPEND
+func NewFileStore(path string, createFile bool, opts FileOptions) (*file, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
 	}
-	f, err := os.OpenFile(path, flags, os.ModePerm)
-	if err != nil {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	f := &file{
+		path:        path,
+		clicksPath:  base + "-clicks.jsonl",
+		tokensPath:  base + "-tokens.json",
+		auditPath:   base + "-audit.jsonl",
+		historyPath: base + "-history.jsonl",
+		journalPath: base + "-journal.jsonl",
+		links:       map[string]Link{},
+		opts:        opts,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) && !createFile {
 		return nil, err
 	}
-	defer f.Close()
 
-	existing, err := io.ReadAll(f)
-	if err != nil {
-		return nil, err
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if opts.ReadOnly {
+		f.opts.CompactInterval = 0
 	}
-	links := map[string]Link{}
-	if len(existing) > 0 {
-		err = json.Unmarshal(existing, &links)
+	if opts.Journal && opts.ReadOnly {
+		// a missing journal has nothing to replay
+		f.journal, err = os.Open(f.journalPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	} else if opts.Journal {
+		f.journal, err = os.OpenFile(f.journalPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
 		if err != nil {
 			return nil, err
 		}
 	}
+	err = f.load()
+	if err == nil && !opts.ReadOnly {
+		// folds any journal left over from a previous run into the links file
+		// and creates the links file if it does not exist yet
+		err = f.compact()
+	}
+	if err == nil {
+		err = f.loadClicks()
+	}
+	if err == nil {
+		err = f.loadAuditIDs()
+	}
+	if err == nil {
+		err = f.loadRevisionCounts()
+	}
+	if err != nil {
+		if f.journal != nil {
+			f.journal.Close()
+		}
+		return nil, err
+	}
 
-	return &file{
-		path:  path,
-		links: links,
-		mu:    sync.Mutex{},
-	}, nil
+	if f.opts.CompactInterval > 0 {
+		go f.run()
+	} else {
+		close(f.done)
+	}
+	return f, nil
+}
+
+func (f *file) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opts.CompactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			if err := f.refresh(); err != nil {
+				f.opts.Logger.Error(err.Error())
+			}
+		}
+	}
 }
 
-func (f *file) saveLinks() error {
+// refresh reloads the links file if it was changed outside the service,
+// replaying any journaled changes on top, and compacts the journal.
+func (f *file) refresh() error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if info == nil || f.snapshot == nil || !info.ModTime().Equal(f.snapshot.ModTime()) || info.Size() != f.snapshot.Size() {
+		f.opts.Logger.With("path", f.path).Info("links file changed on disk, reloading")
+		if err := f.load(); err != nil {
+			return err
+		}
+		return f.compact()
+	}
+	if f.journal == nil {
+		return nil
+	}
+	journalInfo, err := f.journal.Stat()
+	if err != nil {
+		return err
+	}
+	if journalInfo.Size() == 0 {
+		return nil
+	}
+	return f.compact()
+}
+
+// load replaces the in-memory links with the links file followed by the
+// journal. A partially written final journal entry is ignored, but an entry
+// that cannot be read anywhere else fails the load. Must be called with f.mu
+// held.
+func (f *file) load() error {
+	links := map[string]Link{}
+	data, err := os.ReadFile(f.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &links)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.path, err)
+		}
+	}
+	if f.journal != nil {
+		if _, err := f.journal.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := readJSONLines(f.journal, func(line []byte) error {
+			var entry journalEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return err
+			}
+			applyJournalEntry(links, entry)
+			return nil
+		})
+		if errors.Is(err, errPartialLine) {
+			f.opts.Logger.With("path", f.journalPath).Warn("ignoring incomplete journal entry")
+		} else if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.journalPath, err)
+		}
+	}
+	// links written before owners were added are owned by their creator
+	for name, link := range links {
+		links[name] = link.withDefaultOwners()
+	}
+	f.links = links
+	return nil
+}
+
+func applyJournalEntry(links map[string]Link, entry journalEntry) {
+	switch entry.Op {
+	case journalPut:
+		if entry.Link != nil {
+			links[entry.Name] = *entry.Link
+		}
+	case journalDelete:
+		delete(links, entry.Name)
+	}
+}
+
+// compact atomically rewrites the links file and empties the journal. Must
+// be called with f.mu held.
+func (f *file) compact() error {
+	if err := f.writeSnapshot(); err != nil {
+		return err
+	}
+	if f.journal == nil {
+		return nil
+	}
+	if err := f.journal.Truncate(0); err != nil {
+		return err
+	}
+	return f.journal.Sync()
+}
+
+// writeSnapshot atomically writes the links to the links file. Must be called
+// with f.mu held.
+func (f *file) writeSnapshot() error {
 	data, err := json.Marshal(f.links)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(f.path, data, os.ModePerm)
+	if err := writeFileAtomic(f.path, data); err != nil {
+		return err
+	}
+	f.snapshot, err = os.Stat(f.path)
+	return err
+}
+
+// writeFileAtomic writes data to a temporary file that is synced and renamed
+// over path, so a crash never leaves a partial file behind.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// appendLine appends data and a newline to path, creating it if needed, and
+// syncs it so the line survives a crash once appendLine returns.
+func appendLine(path string, data []byte) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// errPartialLine is returned by readJSONLines when the last line cannot be
+// decoded, which is what a write interrupted by a crash leaves behind.
+var errPartialLine = errors.New("incomplete last line")
+
+// readJSONLines calls decode with each non-empty line of r. If the last line
+// fails to decode it returns errPartialLine, and any other line that fails to
+// decode is an error. The returned offset is where the lines that were read
+// end, so a partial last line can be truncated.
+func readJSONLines(r io.Reader, decode func(line []byte) error) (int64, error) {
+	reader := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return offset, readErr
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := decode(line); err != nil {
+				if readErr == nil {
+					_, peekErr := reader.Peek(1)
+					if peekErr == nil {
+						return offset, fmt.Errorf("invalid line at offset %d: %w", offset, err)
+					}
+					if !errors.Is(peekErr, io.EOF) {
+						return offset, peekErr
+					}
+				}
+				return offset, errPartialLine
+			}
+		}
+		offset += int64(len(line))
+		if readErr != nil {
+			return offset, nil
+		}
+	}
+}
+
+// readLines calls decode with each line of the JSON lines file at path. A
+// missing file has no lines. A partially written last line, left by a crash
+// while appending, is skipped with a warning and truncated unless the store
+// is read-only, so the next append starts on a line of its own.
+func (f *file) readLines(path string, decode func(line []byte) error) error {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	end, err := readJSONLines(in, decode)
+	if errors.Is(err, errPartialLine) {
+		f.opts.Logger.With("path", path).Warn("ignoring incomplete last line")
+		if f.opts.ReadOnly {
+			return nil
+		}
+		return os.Truncate(path, end)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// commit applies entries to the in-memory links and persists them, either by
+// appending them to the journal or by rewriting the links file. The in-memory
+// links are rolled back if persisting fails. Must be called with f.mu held.
+func (f *file) commit(entries ...journalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	previous := map[string]*Link{}
+	for _, entry := range entries {
+		if _, ok := previous[entry.Name]; ok {
+			continue
+		}
+		if link, ok := f.links[entry.Name]; ok {
+			previous[entry.Name] = &link
+		} else {
+			previous[entry.Name] = nil
+		}
+	}
+	for _, entry := range entries {
+		applyJournalEntry(f.links, entry)
+	}
+
+	err := f.persist(entries)
+	if err != nil {
+		for name, link := range previous {
+			if link == nil {
+				delete(f.links, name)
+			} else {
+				f.links[name] = *link
+			}
+		}
+	}
+	return err
+}
+
+func (f *file) persist(entries []journalEntry) error {
+	if f.journal == nil {
+		return f.writeSnapshot()
+	}
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	info, err := f.journal.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = f.journal.Write(buf.Bytes())
+	if err == nil {
+		err = f.journal.Sync()
+	}
+	if err != nil {
+		// drop anything that was written so a partial entry is not followed
+		// by later entries, and a rolled back change is not replayed
+		return errors.Join(err, f.journal.Truncate(info.Size()))
+	}
+	return nil
+}
+
+func putEntry(link Link) journalEntry {
+	return journalEntry{Op: journalPut, Name: link.Name, Link: &link}
+}
+
+func deleteEntry(name string) journalEntry {
+	return journalEntry{Op: journalDelete, Name: name}
+}
+
+// lookup returns the link matching name, including disabled links. Must be
+// called with f.mu held.
+func (f *file) lookup(name string) (Link, bool) {
+	if link, ok := f.links[name]; ok {
+		return link, true
+	}
+	for _, link := range f.links {
+		if strings.EqualFold(link.Name, name) {
+			return link, true
+		}
+	}
+	return Link{}, false
+}
+
+// findByName returns the enabled link matching name. Must be called with f.mu held.
+func (f *file) findByName(name string) (Link, error) {
+	link, ok := f.lookup(name)
+	if !ok || link.Disabled {
+		return Link{}, ErrLinkNotFound
+	}
+	return link, nil
 }
 
 // CreateLink implements Store.
 func (f *file) CreateLink(ctx context.Context, link Link) error {
-	_, err := f.GetLinkByName(ctx, link.Name)
-	if err != nil && err != ErrLinkNotFound {
-		return err
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.lookup(link.Name); ok {
+		return ErrIDExists
 	}
+	link = link.withDefaultOwners()
 	link.Created = time.Now()
 	link.Updated = link.Created
-	f.links[link.Name] = link
-	return f.saveLinks()
+	return f.commit(putEntry(link))
+}
+
+// UpdateLink implements Store.
+func (f *file) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	link, err := f.findByName(name)
+	if err != nil {
+		return err
+	}
+	return f.commit(putEntry(patch.apply(link)))
 }
 
 // DisableLink implements Store.
 func (f *file) DisableLink(ctx context.Context, name string) error {
-	delete(f.links, name)
-	return f.saveLinks()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	link, err := f.findByName(name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	link.Disabled = true
+	link.DisabledAt = &now
+	return f.commit(putEntry(link))
 }
 
-// GetLinkByName implements Store.
-func (f *file) GetLinkByName(ctx context.Context, name string) (Link, error) {
+// RestoreLink implements Store.
+func (f *file) RestoreLink(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	link, ok := f.lookup(name)
+	if !ok || !link.Disabled {
+		return ErrLinkNotFound
+	}
+	link.Disabled = false
+	link.DisabledAt = nil
+	return f.commit(putEntry(link))
+}
+
+// PurgeLink implements Store.
+func (f *file) PurgeLink(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	link, ok := f.lookup(name)
+	if !ok || !link.Disabled {
+		return ErrLinkNotFound
+	}
+	if err := f.commit(deleteEntry(link.Name)); err != nil {
+		return err
+	}
+	return f.purgeRevisions([]string{link.Name})
+}
+
+// PurgeDisabledLinks implements Store.
+func (f *file) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries := []journalEntry{}
+	names := []string{}
+	for name, link := range f.links {
+		if link.Disabled && link.DisabledAt != nil && link.DisabledAt.Before(before) {
+			entries = append(entries, deleteEntry(name))
+			names = append(names, name)
+		}
+	}
+	if err := f.commit(entries...); err != nil {
+		return 0, err
+	}
+	return len(entries), f.purgeRevisions(names)
+}
+
+// GetDisabledLinks implements Store.
+func (f *file) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	links := []Link{}
 	for _, link := range f.links {
-		if !link.Disabled && strings.EqualFold(link.Name, name) {
-			return link, nil
+		if link.Disabled && link.OwnedBy(owners) {
+			links = append(links, link)
 		}
 	}
-	return Link{}, ErrLinkNotFound
+	return links, nil
+}
+
+// GetLinkByName implements Store.
+func (f *file) GetLinkByName(ctx context.Context, name string) (Link, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.findByName(name)
 }
 
 // GetLinkByURL implements Store.
 func (f *file) GetLinkByURL(ctx context.Context, url string) (Link, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	for _, link := range f.links {
 		if !link.Disabled && strings.EqualFold(link.URL, url) {
 			return link, nil
@@ -99,10 +604,12 @@ func (f *file) GetLinkByURL(ctx context.Context, url string) (Link, error) {
 }
 
 // GetOwnedLinks implements Store.
-func (f *file) GetOwnedLinks(ctx context.Context, email string) ([]Link, error) {
+func (f *file) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	links := []Link{}
 	for _, link := range f.links {
-		if !link.Disabled && strings.EqualFold(link.CreatedBy, email) {
+		if !link.Disabled && link.OwnedBy(owners) {
 			links = append(links, link)
 		}
 	}
@@ -111,12 +618,7 @@ func (f *file) GetOwnedLinks(ctx context.Context, email string) ([]Link, error)
 
 // GetPopularLinks implements Store.
 func (f *file) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
-	links := []Link{}
-	for _, link := range f.links {
-		if !link.Disabled {
-			links = append(links, link)
-		}
-	}
+	links := f.enabledLinks()
 	slices.SortFunc(links, func(a Link, b Link) int {
 		return cmp.Compare(b.Views, a.Views)
 	})
@@ -128,12 +630,7 @@ func (f *file) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
 
 // GetRecentLinks implements Store.
 func (f *file) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
-	links := []Link{}
-	for _, link := range f.links {
-		if !link.Disabled {
-			links = append(links, link)
-		}
-	}
+	links := f.enabledLinks()
 	slices.SortFunc(links, func(a Link, b Link) int {
 		return b.Updated.Compare(a.Updated)
 	})
@@ -143,19 +640,39 @@ func (f *file) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
 	return links, nil
 }
 
+func (f *file) enabledLinks() []Link {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	links := []Link{}
+	for _, link := range f.links {
+		if !link.Disabled {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
 // IncrementLinkViews implements Store.
 func (f *file) IncrementLinkViews(ctx context.Context, name string) error {
-	if _, ok := f.links[name]; !ok {
-		return ErrLinkNotFound
+	return f.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store.
+func (f *file) AddLinkViews(ctx context.Context, name string, delta int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	link, err := f.findByName(name)
+	if err != nil {
+		return err
 	}
-	link := f.links[name]
-	link.Views++
-	f.links[name] = link
-	return f.saveLinks()
+	link.Views += delta
+	return f.commit(putEntry(link))
 }
 
 // QueryLinks implements Store.
 func (f *file) QueryLinks(ctx context.Context, query string) ([]Link, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	links := []Link{}
 	for _, link := range f.links {
 		if !link.Disabled && (strings.Contains(strings.ToLower(link.Name), strings.ToLower(query)) || strings.Contains(strings.ToLower(link.Description), strings.ToLower(query))) {
@@ -165,7 +682,414 @@ func (f *file) QueryLinks(ctx context.Context, query string) ([]Link, error) {
 	return links, nil
 }
 
-// Close implements Store.
-func (*file) Close(ctx context.Context) error {
+// WalkLinks implements Store. The links are copied before fn is called so fn
+// may use the store.
+func (f *file) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	f.mu.RLock()
+	links := make([]Link, 0, len(f.links))
+	for _, link := range f.links {
+		links = append(links, link)
+	}
+	f.mu.RUnlock()
+	for _, link := range links {
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportLink implements Store.
+func (f *file) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	link = link.withDefaultOwners()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	existing, ok := f.lookup(link.Name)
+	if ok && !overwrite {
+		return ErrIDExists
+	}
+	if ok && existing.Name != link.Name {
+		return f.commit(deleteEntry(existing.Name), putEntry(link))
+	}
+	return f.commit(putEntry(link))
+}
+
+// RecordClick implements Analytics.
+func (f *file) RecordClick(ctx context.Context, click Click) error {
+	return f.RecordClicks(ctx, []Click{click})
+}
+
+// RecordClicks implements Analytics. Clicks are appended to a JSON lines file
+// next to the links file so recording them never rewrites existing data.
+func (f *file) RecordClicks(ctx context.Context, clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	lines := make([][]byte, 0, len(clicks))
+	for _, click := range clicks {
+		data, err := json.Marshal(click)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, data)
+	}
+	f.clicksMu.Lock()
+	defer f.clicksMu.Unlock()
+	if err := appendLine(f.clicksPath, bytes.Join(lines, []byte{'\n'})); err != nil {
+		return err
+	}
+	f.clicks = append(f.clicks, clicks...)
+	return nil
+}
+
+// GetClickStats implements Analytics.
+func (f *file) GetClickStats(ctx context.Context, name string, interval StatsInterval, since time.Time) ([]ClickBucket, error) {
+	f.clicksMu.Lock()
+	defer f.clicksMu.Unlock()
+	return bucketClicks(f.clicks, name, interval, since), nil
+}
+
+// PurgeClicks implements Analytics. The clicks file is rewritten without the
+// purged clicks.
+func (f *file) PurgeClicks(ctx context.Context, before time.Time) (int, error) {
+	f.clicksMu.Lock()
+	defer f.clicksMu.Unlock()
+	kept := slices.DeleteFunc(slices.Clone(f.clicks), func(click Click) bool {
+		return click.Timestamp.Before(before)
+	})
+	count := len(f.clicks) - len(kept)
+	if count == 0 {
+		return 0, nil
+	}
+	var buf bytes.Buffer
+	for _, click := range kept {
+		data, err := json.Marshal(click)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	if err := writeFileAtomic(f.clicksPath, buf.Bytes()); err != nil {
+		return 0, err
+	}
+	f.clicks = kept
+	return count, nil
+}
+
+// loadClicks reads the clicks file once on open so stats are served from
+// memory.
+func (f *file) loadClicks() error {
+	f.clicksMu.Lock()
+	defer f.clicksMu.Unlock()
+	clicks := []Click{}
+	err := f.readLines(f.clicksPath, func(line []byte) error {
+		var click Click
+		if err := json.Unmarshal(line, &click); err != nil {
+			return err
+		}
+		clicks = append(clicks, click)
+		return nil
+	})
+	f.clicks = clicks
+	return err
+}
+
+// fileToken is how an APIToken is written to the tokens file, which unlike
+// API responses has to include the hash.
+type fileToken struct {
+	APIToken
+	Hash string `json:"hash"`
+}
+
+// readTokens returns the tokens file keyed by token ID. Must be called with
+// f.tokensMu held.
+func (f *file) readTokens() (map[string]APIToken, error) {
+	tokens := map[string]APIToken{}
+	data, err := os.ReadFile(f.tokensPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string]fileToken{}
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.tokensPath, err)
+	}
+	for id, token := range stored {
+		token.APIToken.Hash = token.Hash
+		tokens[id] = token.APIToken
+	}
+	return tokens, nil
+}
+
+// writeTokens replaces the tokens file. Must be called with f.tokensMu held.
+func (f *file) writeTokens(tokens map[string]APIToken) error {
+	stored := make(map[string]fileToken, len(tokens))
+	for id, token := range tokens {
+		stored[id] = fileToken{APIToken: token, Hash: token.Hash}
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.tokensPath, data)
+}
+
+// CreateToken implements Tokens. Tokens are kept in their own file next to
+// the links file, as they change rarely.
+func (f *file) CreateToken(ctx context.Context, token APIToken) error {
+	f.tokensMu.Lock()
+	defer f.tokensMu.Unlock()
+	tokens, err := f.readTokens()
+	if err != nil {
+		return err
+	}
+	if tokenExists(tokens, token) {
+		return ErrIDExists
+	}
+	tokens[token.ID] = token
+	return f.writeTokens(tokens)
+}
+
+// GetTokenByHash implements Tokens.
+func (f *file) GetTokenByHash(ctx context.Context, hash string) (APIToken, error) {
+	f.tokensMu.Lock()
+	defer f.tokensMu.Unlock()
+	tokens, err := f.readTokens()
+	if err != nil {
+		return APIToken{}, err
+	}
+	for _, token := range tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return APIToken{}, ErrTokenNotFound
+}
+
+// GetTokens implements Tokens.
+func (f *file) GetTokens(ctx context.Context, owner string) ([]APIToken, error) {
+	f.tokensMu.Lock()
+	defer f.tokensMu.Unlock()
+	tokens, err := f.readTokens()
+	if err != nil {
+		return nil, err
+	}
+	all := make([]APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		all = append(all, token)
+	}
+	return filterTokens(all, owner), nil
+}
+
+// DeleteToken implements Tokens.
+func (f *file) DeleteToken(ctx context.Context, owner string, id string) error {
+	f.tokensMu.Lock()
+	defer f.tokensMu.Unlock()
+	tokens, err := f.readTokens()
+	if err != nil {
+		return err
+	}
+	token, ok := tokens[id]
+	if !ok || !strings.EqualFold(token.Owner, owner) {
+		return ErrTokenNotFound
+	}
+	delete(tokens, id)
+	return f.writeTokens(tokens)
+}
+
+// RecordAuditEvent implements Audit. Like clicks, events are appended to a
+// JSON lines file and never rewritten.
+func (f *file) RecordAuditEvent(ctx context.Context, event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f.auditMu.Lock()
+	defer f.auditMu.Unlock()
+	if f.auditIDs[event.ID] {
+		return ErrIDExists
+	}
+	if err := appendLine(f.auditPath, data); err != nil {
+		return err
+	}
+	f.auditIDs[event.ID] = true
+	return nil
+}
+
+// loadAuditIDs reads the IDs of the events already in the audit file.
+func (f *file) loadAuditIDs() error {
+	f.auditMu.Lock()
+	defer f.auditMu.Unlock()
+	events, err := f.readAuditEvents()
+	if err != nil {
+		return err
+	}
+	f.auditIDs = make(map[string]bool, len(events))
+	for _, event := range events {
+		f.auditIDs[event.ID] = true
+	}
+	return nil
+}
+
+// GetAuditEvents implements Audit.
+func (f *file) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	f.auditMu.Lock()
+	defer f.auditMu.Unlock()
+	events, err := f.readAuditEvents()
+	if err != nil {
+		return nil, err
+	}
+	return filterAuditEvents(events, filter), nil
+}
+
+// readAuditEvents returns every event in the audit file. Must be called with
+// f.auditMu held.
+func (f *file) readAuditEvents() ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	err := f.readLines(f.auditPath, func(line []byte) error {
+		var event AuditEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// AddRevision implements Revisions. Revisions are appended to a JSON lines
+// file like audit events.
+func (f *file) AddRevision(ctx context.Context, revision LinkRevision) (LinkRevision, error) {
+	f.historyMu.Lock()
+	defer f.historyMu.Unlock()
+	key := strings.ToLower(revision.Link)
+	revision.Number = f.revisionCounts[key] + 1
+	data, err := json.Marshal(revision)
+	if err != nil {
+		return revision, err
+	}
+	if err := appendLine(f.historyPath, data); err != nil {
+		return revision, err
+	}
+	f.revisionCounts[key] = revision.Number
+	return revision, nil
+}
+
+// loadRevisionCounts counts the revisions of each link in the history file.
+func (f *file) loadRevisionCounts() error {
+	f.historyMu.Lock()
+	defer f.historyMu.Unlock()
+	revisions, err := f.readRevisions()
+	if err != nil {
+		return err
+	}
+	f.revisionCounts = map[string]int{}
+	for _, revision := range revisions {
+		f.revisionCounts[strings.ToLower(revision.Link)]++
+	}
+	return nil
+}
+
+// purgeRevisions rewrites the history file without the revisions of the
+// purged links, so a new link with the same name starts a new history.
+func (f *file) purgeRevisions(names []string) error {
+	purged := map[string]bool{}
+	for _, name := range names {
+		purged[strings.ToLower(name)] = true
+	}
+	f.historyMu.Lock()
+	defer f.historyMu.Unlock()
+	found := false
+	for key := range purged {
+		found = found || f.revisionCounts[key] > 0
+	}
+	if !found {
+		return nil
+	}
+	revisions, err := f.readRevisions()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, revision := range revisions {
+		if purged[strings.ToLower(revision.Link)] {
+			continue
+		}
+		data, err := json.Marshal(revision)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	if err := writeFileAtomic(f.historyPath, buf.Bytes()); err != nil {
+		return err
+	}
+	for key := range purged {
+		delete(f.revisionCounts, key)
+	}
 	return nil
 }
+
+// GetRevisions implements Revisions.
+func (f *file) GetRevisions(ctx context.Context, name string) ([]LinkRevision, error) {
+	f.historyMu.Lock()
+	defer f.historyMu.Unlock()
+	revisions, err := f.readRevisions()
+	if err != nil {
+		return nil, err
+	}
+	return linkRevisions(revisions, name), nil
+}
+
+// readRevisions returns every revision in the history file. Must be called
+// with f.historyMu held.
+func (f *file) readRevisions() ([]LinkRevision, error) {
+	revisions := []LinkRevision{}
+	err := f.readLines(f.historyPath, func(line []byte) error {
+		var revision LinkRevision
+		if err := json.Unmarshal(line, &revision); err != nil {
+			return err
+		}
+		revisions = append(revisions, revision)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Ping implements Store by checking that the links file can still be read,
+// since links are served from memory.
+func (f *file) Ping(ctx context.Context) error {
+	_, err := os.Stat(f.path)
+	return err
+}
+
+// Close implements Store. Any journaled changes are compacted into the links
+// file before the journal is closed.
+func (f *file) Close(ctx context.Context) error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		<-f.done
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.journal == nil {
+			return
+		}
+		if !f.opts.ReadOnly {
+			err = f.compact()
+		}
+		if closeErr := f.journal.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
@@ -0,0 +1,326 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStoreJournal(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{Journal: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs", CreatedBy: "user@example.com"}))
+	assert.ErrorIs(t, s.CreateLink(ctx, store.Link{Name: "DOCS", URL: "https://example.com"}), store.ErrIDExists)
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "oncall", URL: "https://example.com/oncall"}))
+	assert.NoError(t, s.DisableLink(ctx, "oncall"))
+	assert.NoError(t, s.PurgeLink(ctx, "oncall"))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.IncrementLinkViews(ctx, "docs")
+		}()
+	}
+	wg.Wait()
+
+	// simulates a crash: the journal is replayed without compacting on Close
+	// and a partially written entry at its end is ignored
+	journal, err := os.OpenFile(filepath.Join(filepath.Dir(path), "links-journal.jsonl"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	journal.WriteString(`{"op":"put","name":"partial","link":{"na`)
+	journal.Close()
+
+	reopened, err := store.NewFileStore(path, false, store.FileOptions{Journal: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer reopened.Close(ctx)
+	link, err := reopened.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 20, link.Views)
+	}
+	_, err = reopened.GetLinkByName(ctx, "oncall")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	_, err = reopened.GetLinkByName(ctx, "partial")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+
+	matches, _ := filepath.Glob(path + ".tmp-*")
+	assert.Empty(t, matches)
+}
+
+func TestFileStoreJournalLargeEntry(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{Journal: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	description := strings.Repeat("a", 2<<20)
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs", Description: description}))
+
+	// reopened without closing so the entry is replayed from the journal
+	reopened, err := store.NewFileStore(path, false, store.FileOptions{Journal: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer reopened.Close(ctx)
+	link, err := reopened.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, description, link.Description)
+	}
+}
+
+func TestFileStoreJournalInvalidEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.json")
+	journal := `{"op":"put","name":"docs","link":{"name":"docs","url":"https://example.com/docs"}}
+{"op":"put","name":"partial","link":{"na
+{"op":"put","name":"oncall","link":{"name":"oncall","url":"https://example.com/oncall"}}
+`
+	if !assert.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(path), "links-journal.jsonl"), []byte(journal), 0o644)) {
+		t.FailNow()
+	}
+	_, err := store.NewFileStore(path, true, store.FileOptions{Journal: true})
+	assert.Error(t, err, "entries after the invalid one would be lost")
+}
+
+func TestFileStoreReloadsExternalChanges(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{CompactInterval: 10 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close(ctx)
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"}))
+
+	other, err := store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, other.CreateLink(ctx, store.Link{Name: "wiki", URL: "https://example.com/wiki"}))
+
+	assert.Eventually(t, func() bool {
+		_, err := s.GetLinkByName(ctx, "wiki")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFileStorePing(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close(ctx)
+
+	assert.NoError(t, s.Ping(ctx))
+	assert.NoError(t, os.Remove(path))
+	assert.Error(t, s.Ping(ctx), "the links file is gone")
+}
+
+func TestFileStoreAuditIDsSurviveReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, s.RecordAuditEvent(ctx, store.AuditEvent{ID: "event", Time: time.Now(), Action: store.AuditActionCreate, Link: "docs"}))
+	assert.NoError(t, s.Close(ctx))
+
+	reopened, err := store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer reopened.Close(ctx)
+	assert.ErrorIs(t, reopened.RecordAuditEvent(ctx, store.AuditEvent{ID: "event", Time: time.Now(), Link: "docs"}), store.ErrIDExists)
+	assert.NoError(t, reopened.RecordAuditEvent(ctx, store.AuditEvent{ID: "other", Time: time.Now(), Action: store.AuditActionUpdate, Link: "docs"}))
+	events, err := reopened.GetAuditEvents(ctx, store.AuditFilter{Link: "docs"})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestFileStoreClicksSurviveReopenAndPurge(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	wednesday := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.RecordClicks(ctx, []store.Click{
+		{Link: "docs", Timestamp: wednesday.AddDate(0, 0, -30)},
+		{Link: "docs", Timestamp: wednesday, User: "a@example.com"},
+	}))
+	assert.NoError(t, s.RecordClick(ctx, store.Click{Link: "docs", Timestamp: wednesday.Add(time.Hour)}))
+	assert.NoError(t, s.Close(ctx))
+
+	reopened, err := store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	count, err := reopened.PurgeClicks(ctx, wednesday.AddDate(0, 0, -7))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.NoError(t, reopened.Close(ctx))
+
+	reopened, err = store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer reopened.Close(ctx)
+	daily, err := reopened.GetClickStats(ctx, "docs", store.StatsIntervalDay, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []store.ClickBucket{
+		{Start: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Clicks: 2, UniqueUsers: 1},
+	}, daily)
+}
+
+func TestFileStoreIgnoresPartialAppendedLines(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	now := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.RecordClick(ctx, store.Click{Link: "docs", Timestamp: now}))
+	assert.NoError(t, s.RecordAuditEvent(ctx, store.AuditEvent{ID: "event", Time: now, Action: store.AuditActionCreate, Link: "docs"}))
+	_, err = s.AddRevision(ctx, store.LinkRevision{Link: "docs", URL: "https://example.com"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close(ctx))
+
+	// simulates a crash partway through appending to each file
+	for _, name := range []string{"links-clicks.jsonl", "links-audit.jsonl", "links-history.jsonl"} {
+		out, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_APPEND, 0o644)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		out.WriteString(`{"link":"do`)
+		out.Close()
+	}
+
+	reopened, err := store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, reopened.RecordClick(ctx, store.Click{Link: "docs", Timestamp: now}))
+	assert.NoError(t, reopened.RecordAuditEvent(ctx, store.AuditEvent{ID: "other", Time: now, Action: store.AuditActionUpdate, Link: "docs"}))
+	revision, err := reopened.AddRevision(ctx, store.LinkRevision{Link: "docs", URL: "https://example.com/new"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, revision.Number)
+	assert.NoError(t, reopened.Close(ctx))
+
+	// the partial lines were truncated, so the appends above are readable
+	reopened, err = store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer reopened.Close(ctx)
+	daily, err := reopened.GetClickStats(ctx, "docs", store.StatsIntervalDay, time.Time{})
+	if assert.NoError(t, err) && assert.Len(t, daily, 1) {
+		assert.Equal(t, 2, daily[0].Clicks)
+	}
+	events, err := reopened.GetAuditEvents(ctx, store.AuditFilter{Link: "docs"})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	revisions, err := reopened.GetRevisions(ctx, "docs")
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+}
+
+func TestFileStoreRevisionNumbersSurviveReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, name := range []string{"docs", "DOCS", "wiki"} {
+		_, err := s.AddRevision(ctx, store.LinkRevision{Link: name, URL: "https://example.com"})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, s.Close(ctx))
+
+	reopened, err := store.NewFileStore(path, false, store.FileOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer reopened.Close(ctx)
+	revision, err := reopened.AddRevision(ctx, store.LinkRevision{Link: "Docs", URL: "https://example.com/new"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, revision.Number)
+	revisions, err := reopened.GetRevisions(ctx, "docs")
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+}
+
+func TestOpenMissingStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, cfg := range []config.StoreConfig{
+		{StoreType: config.StoreTypeFile, File: config.FileConfig{Path: filepath.Join(dir, "links.json")}},
+		{StoreType: config.StoreTypeSQLite, SQLite: config.SQLiteConfig{Path: filepath.Join(dir, "links.db")}},
+	} {
+		for _, opts := range []store.Options{{MustExist: true}, {ReadOnly: true}} {
+			_, err := store.Open(ctx, cfg, opts)
+			assert.ErrorIs(t, err, store.ErrStoreNotFound, cfg.StoreType)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "nothing is created")
+}
+
+func TestFileStoreReadOnly(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := store.NewFileStore(path, true, store.FileOptions{Journal: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/docs"}))
+	journal, err := os.ReadFile(filepath.Join(filepath.Dir(path), "links-journal.jsonl"))
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, journal) {
+		t.FailNow()
+	}
+	before, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	cfg := config.StoreConfig{StoreType: config.StoreTypeFile, File: config.FileConfig{Path: path, Journal: true}}
+	readOnly, err := store.Open(ctx, cfg, store.Options{ReadOnly: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = readOnly.GetLinkByName(ctx, "docs")
+	assert.NoError(t, err, "journaled links are read")
+	assert.NoError(t, readOnly.Close(ctx))
+
+	// neither opening nor closing compacts the journal into the links file
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	journalAfter, err := os.ReadFile(filepath.Join(filepath.Dir(path), "links-journal.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(journal), string(journalAfter))
+	assert.NoError(t, s.Close(ctx))
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instrumented wraps a Store to export the latency and errors of every Store
+// method as Prometheus metrics. ErrLinkNotFound and ErrIDExists are expected
+// answers rather than failures, so they are not counted as errors.
+type instrumented struct {
+	Store
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+var _ Store = (*instrumented)(nil)
+
+func NewInstrumentedStore(s Store, registerer prometheus.Registerer) (*instrumented, error) {
+	i := &instrumented{
+		Store: s,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "golinks",
+			Subsystem: "store",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of store operations by store.Store method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "golinks",
+			Subsystem: "store",
+			Name:      "operation_errors_total",
+			Help:      "Failed store operations by store.Store method.",
+		}, []string{"method"}),
+	}
+	for _, collector := range []prometheus.Collector{i.duration, i.errors} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return i, nil
+}
+
+func (i *instrumented) observe(method string, start time.Time, err error) {
+	i.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, ErrLinkNotFound) && !errors.Is(err, ErrIDExists) {
+		i.errors.WithLabelValues(method).Inc()
+	}
+}
+
+// CreateLink implements Store.
+func (i *instrumented) CreateLink(ctx context.Context, link Link) error {
+	start := time.Now()
+	err := i.Store.CreateLink(ctx, link)
+	i.observe("CreateLink", start, err)
+	return err
+}
+
+// GetLinkByName implements Store.
+func (i *instrumented) GetLinkByName(ctx context.Context, name string) (Link, error) {
+	start := time.Now()
+	link, err := i.Store.GetLinkByName(ctx, name)
+	i.observe("GetLinkByName", start, err)
+	return link, err
+}
+
+// GetLinkByURL implements Store.
+func (i *instrumented) GetLinkByURL(ctx context.Context, url string) (Link, error) {
+	start := time.Now()
+	link, err := i.Store.GetLinkByURL(ctx, url)
+	i.observe("GetLinkByURL", start, err)
+	return link, err
+}
+
+// UpdateLink implements Store.
+func (i *instrumented) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	start := time.Now()
+	err := i.Store.UpdateLink(ctx, name, patch)
+	i.observe("UpdateLink", start, err)
+	return err
+}
+
+// DisableLink implements Store.
+func (i *instrumented) DisableLink(ctx context.Context, name string) error {
+	start := time.Now()
+	err := i.Store.DisableLink(ctx, name)
+	i.observe("DisableLink", start, err)
+	return err
+}
+
+// RestoreLink implements Store.
+func (i *instrumented) RestoreLink(ctx context.Context, name string) error {
+	start := time.Now()
+	err := i.Store.RestoreLink(ctx, name)
+	i.observe("RestoreLink", start, err)
+	return err
+}
+
+// PurgeLink implements Store.
+func (i *instrumented) PurgeLink(ctx context.Context, name string) error {
+	start := time.Now()
+	err := i.Store.PurgeLink(ctx, name)
+	i.observe("PurgeLink", start, err)
+	return err
+}
+
+// PurgeDisabledLinks implements Store.
+func (i *instrumented) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	start := time.Now()
+	count, err := i.Store.PurgeDisabledLinks(ctx, before)
+	i.observe("PurgeDisabledLinks", start, err)
+	return count, err
+}
+
+// GetDisabledLinks implements Store.
+func (i *instrumented) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	start := time.Now()
+	links, err := i.Store.GetDisabledLinks(ctx, owners)
+	i.observe("GetDisabledLinks", start, err)
+	return links, err
+}
+
+// GetPopularLinks implements Store.
+func (i *instrumented) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
+	start := time.Now()
+	links, err := i.Store.GetPopularLinks(ctx, size)
+	i.observe("GetPopularLinks", start, err)
+	return links, err
+}
+
+// GetRecentLinks implements Store.
+func (i *instrumented) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
+	start := time.Now()
+	links, err := i.Store.GetRecentLinks(ctx, size)
+	i.observe("GetRecentLinks", start, err)
+	return links, err
+}
+
+// GetOwnedLinks implements Store.
+func (i *instrumented) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
+	start := time.Now()
+	links, err := i.Store.GetOwnedLinks(ctx, owners)
+	i.observe("GetOwnedLinks", start, err)
+	return links, err
+}
+
+// IncrementLinkViews implements Store.
+func (i *instrumented) IncrementLinkViews(ctx context.Context, name string) error {
+	start := time.Now()
+	err := i.Store.IncrementLinkViews(ctx, name)
+	i.observe("IncrementLinkViews", start, err)
+	return err
+}
+
+// AddLinkViews implements Store.
+func (i *instrumented) AddLinkViews(ctx context.Context, name string, delta int) error {
+	start := time.Now()
+	err := i.Store.AddLinkViews(ctx, name, delta)
+	i.observe("AddLinkViews", start, err)
+	return err
+}
+
+// QueryLinks implements Store.
+func (i *instrumented) QueryLinks(ctx context.Context, query string) ([]Link, error) {
+	start := time.Now()
+	links, err := i.Store.QueryLinks(ctx, query)
+	i.observe("QueryLinks", start, err)
+	return links, err
+}
+
+// WalkLinks implements Store. The latency includes the time spent in fn.
+func (i *instrumented) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	start := time.Now()
+	err := i.Store.WalkLinks(ctx, fn)
+	i.observe("WalkLinks", start, err)
+	return err
+}
+
+// ImportLink implements Store.
+func (i *instrumented) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	start := time.Now()
+	err := i.Store.ImportLink(ctx, link, overwrite)
+	i.observe("ImportLink", start, err)
+	return err
+}
+
+// Ping implements Store.
+func (i *instrumented) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := i.Store.Ping(ctx)
+	i.observe("Ping", start, err)
+	return err
+}
+
+// Close implements Store.
+func (i *instrumented) Close(ctx context.Context) error {
+	start := time.Now()
+	err := i.Store.Close(ctx)
+	i.observe("Close", start, err)
+	return err
+}
@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/imdevinc/go-links/internal/store/storetest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) UpdateLink(ctx context.Context, name string, patch store.LinkPatch) error {
+	return errors.New("connection refused")
+}
+
+func TestInstrumentedConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := store.NewInstrumentedStore(store.NewMemoryStore(), prometheus.NewRegistry())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return s
+	})
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	s, err := store.NewInstrumentedStore(failingStore{store.NewMemoryStore()}, registry)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com"}))
+	assert.ErrorIs(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com"}), store.ErrIDExists)
+	_, err = s.GetLinkByName(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	assert.Error(t, s.UpdateLink(ctx, "docs", store.LinkPatch{}))
+
+	count, err := testutil.GatherAndCount(registry, "golinks_store_operation_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	// missing and existing links are answers, not errors
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP golinks_store_operation_errors_total Failed store operations by store.Store method.
+# TYPE golinks_store_operation_errors_total counter
+golinks_store_operation_errors_total{method="UpdateLink"} 1
+`), "golinks_store_operation_errors_total"))
+
+	_, err = store.NewInstrumentedStore(store.NewMemoryStore(), registry)
+	assert.Error(t, err, "metrics can only be registered once")
+}
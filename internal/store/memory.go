@@ -9,50 +9,155 @@ import (
 	"time"
 )
 
+// maxMemoryClicks caps how many clicks the memory store keeps, dropping the
+// oldest first, so it cannot grow without bound.
+const maxMemoryClicks = 100_000
+
 type memory struct {
+	// links is keyed by the lowercased link name so lookups are case-insensitive.
 	links sync.Map
+	// mu serializes changes so read-modify-write operations are not lost.
+	mu       sync.Mutex
+	clicks   []Click
+	clicksMu sync.Mutex
+	tokens   map[string]APIToken
+	tokensMu sync.Mutex
+	audit    []AuditEvent
+	auditMu  sync.Mutex
+	// revisions are keyed by memoryKey of the link name
+	revisions   map[string][]LinkRevision
+	revisionsMu sync.Mutex
 }
 
 var _ Store = (*memory)(nil)
+var _ Analytics = (*memory)(nil)
+var _ Tokens = (*memory)(nil)
+var _ Audit = (*memory)(nil)
+var _ Revisions = (*memory)(nil)
+
+func memoryKey(name string) string {
+	return strings.ToLower(name)
+}
 
 func NewMemoryStore() *memory {
 	return &memory{
-		links: sync.Map{},
+		links:     sync.Map{},
+		tokens:    map[string]APIToken{},
+		revisions: map[string][]LinkRevision{},
 	}
 }
 
-// DeleteLink implements Store.
+// DisableLink implements Store.
 func (m *memory) DisableLink(ctx context.Context, name string) error {
-	m.links.Delete(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, err := m.GetLinkByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	link.Disabled = true
+	link.DisabledAt = &now
+	m.links.Store(memoryKey(link.Name), link)
 	return nil
 }
 
-// CreateLink implements Store.
-func (m *memory) CreateLink(ctx context.Context, link Link) error {
-	if _, ok := m.links.Load(link.Name); ok {
-		return ErrIDExists
+// RestoreLink implements Store.
+func (m *memory) RestoreLink(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.links.Load(memoryKey(name))
+	if !ok || !l.(Link).Disabled {
+		return ErrLinkNotFound
 	}
-	link.Created = time.Now()
-	link.Updated = link.Created
-	m.links.Store(link.Name, link)
+	link := l.(Link)
+	link.Disabled = false
+	link.DisabledAt = nil
+	m.links.Store(memoryKey(name), link)
 	return nil
 }
 
-// GetLinkByName implements Store.
-func (m *memory) GetLinkByName(ctx context.Context, name string) (Link, error) {
-	var link Link
+// PurgeLink implements Store.
+func (m *memory) PurgeLink(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.links.Load(memoryKey(name))
+	if !ok || !l.(Link).Disabled {
+		return ErrLinkNotFound
+	}
+	m.links.Delete(memoryKey(name))
+	m.purgeRevisions(memoryKey(name))
+	return nil
+}
+
+// PurgeDisabledLinks implements Store.
+func (m *memory) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
 	m.links.Range(func(key, value any) bool {
 		l := value.(Link)
-		if !l.Disabled && strings.EqualFold(l.Name, name) {
-			link = l
-			return false
+		if l.Disabled && l.DisabledAt != nil && l.DisabledAt.Before(before) {
+			m.links.Delete(key)
+			m.purgeRevisions(key.(string))
+			count++
 		}
 		return true
 	})
-	if link.Name == "" {
+	return count, nil
+}
+
+// purgeRevisions forgets the revisions of a purged link so a new link with
+// the same name starts a new history.
+func (m *memory) purgeRevisions(key string) {
+	m.revisionsMu.Lock()
+	defer m.revisionsMu.Unlock()
+	delete(m.revisions, key)
+}
+
+// GetDisabledLinks implements Store.
+func (m *memory) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	links := []Link{}
+	m.links.Range(func(key, value any) bool {
+		l := value.(Link)
+		if l.Disabled && l.OwnedBy(owners) {
+			links = append(links, l)
+		}
+		return true
+	})
+	return links, nil
+}
+
+// CreateLink implements Store.
+func (m *memory) CreateLink(ctx context.Context, link Link) error {
+	link = link.withDefaultOwners()
+	link.Created = time.Now()
+	link.Updated = link.Created
+	if _, loaded := m.links.LoadOrStore(memoryKey(link.Name), link); loaded {
+		return ErrIDExists
+	}
+	return nil
+}
+
+// UpdateLink implements Store.
+func (m *memory) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, err := m.GetLinkByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	m.links.Store(memoryKey(link.Name), patch.apply(link))
+	return nil
+}
+
+// GetLinkByName implements Store.
+func (m *memory) GetLinkByName(ctx context.Context, name string) (Link, error) {
+	l, ok := m.links.Load(memoryKey(name))
+	if !ok || l.(Link).Disabled {
 		return Link{}, ErrLinkNotFound
 	}
-	return link, nil
+	return l.(Link), nil
 }
 
 // GetLinkByURL implements Store.
@@ -108,11 +213,11 @@ func (m *memory) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
 	return links, nil
 }
 
-func (m *memory) GetOwnedLinks(ctx context.Context, email string) ([]Link, error) {
+func (m *memory) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
 	links := []Link{}
 	m.links.Range(func(key, value any) bool {
 		l := value.(Link)
-		if !l.Disabled {
+		if !l.Disabled && l.OwnedBy(owners) {
 			links = append(links, l)
 		}
 		return true
@@ -121,13 +226,19 @@ func (m *memory) GetOwnedLinks(ctx context.Context, email string) ([]Link, error
 }
 
 func (m *memory) IncrementLinkViews(ctx context.Context, name string) error {
-	l, ok := m.links.Load(name)
-	if !ok {
-		return ErrLinkNotFound
+	return m.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store.
+func (m *memory) AddLinkViews(ctx context.Context, name string, delta int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, err := m.GetLinkByName(ctx, name)
+	if err != nil {
+		return err
 	}
-	link := l.(Link)
-	link.Views++
-	m.links.Store(name, link)
+	link.Views += delta
+	m.links.Store(memoryKey(name), link)
 	return nil
 }
 
@@ -143,6 +254,154 @@ func (m *memory) QueryLinks(ctx context.Context, query string) ([]Link, error) {
 	return links, nil
 }
 
+// RecordClick implements Analytics.
+func (m *memory) RecordClick(ctx context.Context, click Click) error {
+	return m.RecordClicks(ctx, []Click{click})
+}
+
+// RecordClicks implements Analytics. Once more than maxMemoryClicks clicks
+// are held, the oldest are dropped down to 90% of the cap so the copy is not
+// repeated on every click.
+func (m *memory) RecordClicks(ctx context.Context, clicks []Click) error {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+	m.clicks = append(m.clicks, clicks...)
+	if len(m.clicks) > maxMemoryClicks {
+		keep := maxMemoryClicks - maxMemoryClicks/10
+		m.clicks = slices.Clone(m.clicks[len(m.clicks)-keep:])
+	}
+	return nil
+}
+
+// GetClickStats implements Analytics.
+func (m *memory) GetClickStats(ctx context.Context, name string, interval StatsInterval, since time.Time) ([]ClickBucket, error) {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+	return bucketClicks(m.clicks, name, interval, since), nil
+}
+
+// PurgeClicks implements Analytics.
+func (m *memory) PurgeClicks(ctx context.Context, before time.Time) (int, error) {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+	count := len(m.clicks)
+	m.clicks = slices.DeleteFunc(m.clicks, func(click Click) bool {
+		return click.Timestamp.Before(before)
+	})
+	return count - len(m.clicks), nil
+}
+
+// CreateToken implements Tokens.
+func (m *memory) CreateToken(ctx context.Context, token APIToken) error {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	if tokenExists(m.tokens, token) {
+		return ErrIDExists
+	}
+	m.tokens[token.ID] = token
+	return nil
+}
+
+// GetTokenByHash implements Tokens.
+func (m *memory) GetTokenByHash(ctx context.Context, hash string) (APIToken, error) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	for _, token := range m.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return APIToken{}, ErrTokenNotFound
+}
+
+// GetTokens implements Tokens.
+func (m *memory) GetTokens(ctx context.Context, owner string) ([]APIToken, error) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	tokens := make([]APIToken, 0, len(m.tokens))
+	for _, token := range m.tokens {
+		tokens = append(tokens, token)
+	}
+	return filterTokens(tokens, owner), nil
+}
+
+// DeleteToken implements Tokens.
+func (m *memory) DeleteToken(ctx context.Context, owner string, id string) error {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	token, ok := m.tokens[id]
+	if !ok || !strings.EqualFold(token.Owner, owner) {
+		return ErrTokenNotFound
+	}
+	delete(m.tokens, id)
+	return nil
+}
+
+// WalkLinks implements Store.
+func (m *memory) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	var err error
+	m.links.Range(func(key, value any) bool {
+		err = fn(value.(Link))
+		return err == nil
+	})
+	return err
+}
+
+// ImportLink implements Store.
+func (m *memory) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	link = link.withDefaultOwners()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if overwrite {
+		m.links.Store(memoryKey(link.Name), link)
+		return nil
+	}
+	if _, loaded := m.links.LoadOrStore(memoryKey(link.Name), link); loaded {
+		return ErrIDExists
+	}
+	return nil
+}
+
+// RecordAuditEvent implements Audit.
+func (m *memory) RecordAuditEvent(ctx context.Context, event AuditEvent) error {
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+	if slices.ContainsFunc(m.audit, func(existing AuditEvent) bool { return existing.ID == event.ID }) {
+		return ErrIDExists
+	}
+	m.audit = append(m.audit, event)
+	return nil
+}
+
+// GetAuditEvents implements Audit.
+func (m *memory) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+	return filterAuditEvents(m.audit, filter), nil
+}
+
+// AddRevision implements Revisions.
+func (m *memory) AddRevision(ctx context.Context, revision LinkRevision) (LinkRevision, error) {
+	m.revisionsMu.Lock()
+	defer m.revisionsMu.Unlock()
+	key := memoryKey(revision.Link)
+	revision.Number = len(m.revisions[key]) + 1
+	m.revisions[key] = append(m.revisions[key], revision)
+	return revision, nil
+}
+
+// GetRevisions implements Revisions.
+func (m *memory) GetRevisions(ctx context.Context, name string) ([]LinkRevision, error) {
+	m.revisionsMu.Lock()
+	defer m.revisionsMu.Unlock()
+	return append([]LinkRevision{}, m.revisions[memoryKey(name)]...), nil
+}
+
+// Ping implements Store.
+func (*memory) Ping(ctx context.Context) error {
+	return nil
+}
+
 // Close implements Store.
 func (*memory) Close(ctx context.Context) error {
 	return nil
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/imdevinc/go-links/internal/store"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,8 +51,145 @@ func TestMemoryGetPopular(t *testing.T) {
 			Views: 5,
 		},
 	}
-	diff := cmp.Diff(links, expected)
+	diff := cmp.Diff(links, expected, cmpopts.IgnoreFields(store.Link{}, "Created", "Updated"))
 	if !assert.Equal(t, "", diff) {
 		t.FailNow()
 	}
 }
+
+func TestMemoryUpdateLink(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	err := m.CreateLink(ctx, store.Link{
+		Name:        "test",
+		Description: "original",
+		URL:         "https://example.com",
+		Views:       3,
+		CreatedBy:   "user@example.com",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	original, _ := m.GetLinkByName(ctx, "test")
+	url := "https://example.org"
+	err = m.UpdateLink(ctx, "test", store.LinkPatch{URL: &url})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	updated, err := m.GetLinkByName(ctx, "test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, url, updated.URL)
+	assert.Equal(t, "original", updated.Description)
+	assert.Equal(t, 3, updated.Views)
+	assert.Equal(t, "user@example.com", updated.CreatedBy)
+	assert.Equal(t, original.Created, updated.Created)
+	assert.True(t, updated.Updated.After(original.Updated))
+
+	err = m.UpdateLink(ctx, "missing", store.LinkPatch{URL: &url})
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+}
+
+func TestMemoryDisableRestorePurge(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	m.CreateLink(ctx, store.Link{Name: "test", CreatedBy: "user@example.com"})
+	m.CreateLink(ctx, store.Link{Name: "test2", CreatedBy: "other@example.com"})
+
+	if !assert.NoError(t, m.DisableLink(ctx, "test")) {
+		t.FailNow()
+	}
+	_, err := m.GetLinkByName(ctx, "test")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	assert.ErrorIs(t, m.CreateLink(ctx, store.Link{Name: "test"}), store.ErrIDExists)
+
+	trash, _ := m.GetDisabledLinks(ctx, []string{"user@example.com"})
+	if !assert.Equal(t, 1, len(trash)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "test", trash[0].Name)
+	assert.NotNil(t, trash[0].DisabledAt)
+	trash, _ = m.GetDisabledLinks(ctx, []string{"other@example.com"})
+	assert.Equal(t, 0, len(trash))
+
+	if !assert.NoError(t, m.RestoreLink(ctx, "test")) {
+		t.FailNow()
+	}
+	link, err := m.GetLinkByName(ctx, "test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Nil(t, link.DisabledAt)
+	assert.ErrorIs(t, m.RestoreLink(ctx, "test"), store.ErrLinkNotFound)
+	assert.ErrorIs(t, m.PurgeLink(ctx, "test"), store.ErrLinkNotFound)
+
+	m.DisableLink(ctx, "test")
+	m.DisableLink(ctx, "test2")
+	count, _ := m.PurgeDisabledLinks(ctx, time.Now().Add(-time.Hour))
+	assert.Equal(t, 0, count)
+	count, _ = m.PurgeDisabledLinks(ctx, time.Now())
+	assert.Equal(t, 2, count)
+	assert.ErrorIs(t, m.RestoreLink(ctx, "test"), store.ErrLinkNotFound)
+}
+
+func TestMemoryClickStats(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	// 2024-01-03 is a Wednesday
+	wednesday := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	clicks := []store.Click{
+		{Link: "test", Timestamp: wednesday, User: "a@example.com"},
+		{Link: "test", Timestamp: wednesday.Add(time.Hour), User: "a@example.com"},
+		{Link: "test", Timestamp: wednesday.Add(2 * time.Hour), User: "b@example.com"},
+		{Link: "test", Timestamp: wednesday.AddDate(0, 0, 1)},
+		{Link: "test", Timestamp: wednesday.AddDate(0, 0, 7)},
+		{Link: "other", Timestamp: wednesday},
+		{Link: "test", Timestamp: wednesday.AddDate(0, 0, -30)},
+	}
+	for _, c := range clicks {
+		m.RecordClick(ctx, c)
+	}
+	since := wednesday.AddDate(0, 0, -7)
+
+	daily, err := m.GetClickStats(ctx, "test", store.StatsIntervalDay, since)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected := []store.ClickBucket{
+		{Start: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Clicks: 3, UniqueUsers: 2},
+		{Start: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), Clicks: 1, UniqueUsers: 0},
+		{Start: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Clicks: 1, UniqueUsers: 0},
+	}
+	assert.Equal(t, expected, daily)
+
+	weekly, err := m.GetClickStats(ctx, "test", store.StatsIntervalWeek, since)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected = []store.ClickBucket{
+		{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Clicks: 4, UniqueUsers: 2},
+		{Start: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Clicks: 1, UniqueUsers: 0},
+	}
+	assert.Equal(t, expected, weekly)
+}
+
+func TestMemoryPurgeClicks(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemoryStore()
+	now := time.Now()
+	m.RecordClicks(ctx, []store.Click{
+		{Link: "test", Timestamp: now.AddDate(0, 0, -100)},
+		{Link: "test", Timestamp: now.AddDate(0, 0, -10)},
+		{Link: "test", Timestamp: now},
+	})
+	count, err := m.PurgeClicks(ctx, now.AddDate(0, 0, -90))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	daily, _ := m.GetClickStats(ctx, "test", store.StatsIntervalDay, time.Time{})
+	total := 0
+	for _, bucket := range daily {
+		total += bucket.Clicks
+	}
+	assert.Equal(t, 2, total)
+}
@@ -4,25 +4,73 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type mongodb struct {
 	client     *mongo.Client
 	db         *mongo.Database
 	collection *mongo.Collection
+	clicks     *mongo.Collection
+	tokens     *mongo.Collection
+	audit      *mongo.Collection
+	revisions  *mongo.Collection
 }
 
 const collectionName string = "links"
+const clicksCollectionName string = "clicks"
+const tokensCollectionName string = "tokens"
+const auditCollectionName string = "audit"
+const revisionsCollectionName string = "revisions"
+
+// caseInsensitive is used for every lookup by owner so they match
+// regardless of case, like the other stores. Links are looked up by their
+// key instead.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// mongoLink is a link as it is stored, with its name lowercased as key so
+// lookups by name and the uniqueness of names, regardless of case, use the
+// unique index on key.
+type mongoLink struct {
+	Link `bson:",inline"`
+	Key  string `bson:"key"`
+}
+
+func newMongoLink(link Link) mongoLink {
+	return mongoLink{Link: link, Key: linkKey(link.Name)}
+}
+
+// linkKey returns the key of the link called name.
+func linkKey(name string) string {
+	return strings.ToLower(name)
+}
 
 var _ (Store) = (*mongodb)(nil)
+var _ (Analytics) = (*mongodb)(nil)
+var _ (Subscriber) = (*mongodb)(nil)
+var _ (Tokens) = (*mongodb)(nil)
+var _ (Audit) = (*mongodb)(nil)
+var _ (Revisions) = (*mongodb)(nil)
 
 func NewMongoDBStore(ctx context.Context, user string, password string, host string, databaseName string) (*mongodb, error) {
+	return openMongoDB(ctx, user, password, host, databaseName, Options{})
+}
+
+func openMongoDB(ctx context.Context, user string, password string, host string, databaseName string, opts Options) (*mongodb, error) {
 	connectionString := fmt.Sprintf("mongodb://%s:%s@%s", user, password, host)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connectionString))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connectionString).SetMonitor(newMongoMonitor()))
 	if err != nil {
 		return nil, err
 	}
@@ -38,23 +86,130 @@ func NewMongoDBStore(ctx context.Context, user string, password string, host str
 		}
 	}
 	db := client.Database(databaseName)
+	m := &mongodb{
+		client:     client,
+		db:         db,
+		collection: db.Collection(collectionName),
+		clicks:     db.Collection(clicksCollectionName),
+		tokens:     db.Collection(tokensCollectionName),
+		audit:      db.Collection(auditCollectionName),
+		revisions:  db.Collection(revisionsCollectionName),
+	}
+	if !exists && (opts.MustExist || opts.ReadOnly) {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("%w: database %s", ErrStoreNotFound, databaseName)
+	}
+	if opts.ReadOnly {
+		return m, nil
+	}
 	if !exists {
 		err = db.CreateCollection(ctx, collectionName)
 	}
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(collectionName)
+	// links stored before links had a key get one before it is made unique
+	_, err = m.collection.UpdateMany(ctx,
+		bson.M{"key": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"key": bson.M{"$toLower": "$_id"}}}}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unique index on key, rename links whose names only differ in case: %w", err)
+	}
 	textModel := mongo.IndexModel{Keys: bson.D{{Key: "_id", Value: "text"}, {Key: "description", Value: "text"}}}
-	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{textModel})
+	ownersModel := mongo.IndexModel{Keys: bson.D{{Key: "owners", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)}
+	_, err = m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{textModel, ownersModel})
 	if err != nil {
 		return nil, err
 	}
-	return &mongodb{
-		client:     client,
-		db:         db,
-		collection: collection,
-	}, nil
+	// links created before links could have several owners are owned by their creator
+	_, err = m.collection.UpdateMany(ctx,
+		bson.M{"owners": bson.M{"$exists": false}, "created_by": bson.M{"$ne": ""}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"owners": bson.A{"$created_by"}}}}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.clicks.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "link", Value: 1}, {Key: "timestamp", Value: 1}}},
+		{Keys: bson.D{{Key: "timestamp", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.tokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "owner", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "link", Value: 1}, {Key: "time", Value: -1}}, Options: options.Index().SetCollation(caseInsensitive)},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "time", Value: -1}}, Options: options.Index().SetCollation(caseInsensitive)},
+		{Keys: bson.D{{Key: "time", Value: -1}}, Options: options.Index().SetCollation(caseInsensitive)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "link", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true).SetCollation(caseInsensitive),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// newMongoMonitor records a span for every command sent to the server, using
+// the global tracer provider so commands are only traced when tracing is
+// enabled.
+func newMongoMonitor() *event.CommandMonitor {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	var spans sync.Map
+	finish := func(requestID int64, failure string) {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if failure != "" {
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			attributes := []attribute.KeyValue{
+				semconv.DBSystemMongoDB,
+				semconv.DBNamespace(evt.DatabaseName),
+				semconv.DBOperationName(evt.CommandName),
+			}
+			name := evt.CommandName
+			if collection, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+				attributes = append(attributes, semconv.DBCollectionName(collection))
+				name += " " + collection
+			}
+			_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, "")
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Failure)
+		},
+	}
+}
+
+// Ping implements Store.
+func (m *mongodb) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
 }
 
 func (m *mongodb) Close(ctx context.Context) error {
@@ -66,7 +221,15 @@ func (m *mongodb) Close(ctx context.Context) error {
 
 // CreateLink implements Store.
 func (m *mongodb) CreateLink(ctx context.Context, link Link) error {
-	_, err := m.collection.InsertOne(ctx, link)
+	link = link.withDefaultOwners()
+	link.Created = time.Now()
+	link.Updated = link.Created
+	return m.insertLink(ctx, link)
+}
+
+func (m *mongodb) insertLink(ctx context.Context, link Link) error {
+	// the unique index on key rejects names that only differ in case
+	_, err := m.collection.InsertOne(ctx, newMongoLink(link))
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
 			return ErrIDExists
@@ -76,21 +239,122 @@ func (m *mongodb) CreateLink(ctx context.Context, link Link) error {
 	return nil
 }
 
+// UpdateLink implements Store.
+func (m *mongodb) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	set := bson.M{"updated_at": time.Now()}
+	if patch.Description != nil {
+		set["description"] = *patch.Description
+	}
+	if patch.URL != nil {
+		set["url"] = *patch.URL
+	}
+	if patch.Owners != nil {
+		set["owners"] = patch.Owners
+	}
+	result, err := m.collection.UpdateOne(ctx, bson.M{"key": linkKey(name), "disabled": false}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
 // DisableLink implements Store.
 func (m *mongodb) DisableLink(ctx context.Context, name string) error {
-	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": name})
+	update := bson.M{"$set": bson.M{"disabled": true, "disabled_at": time.Now()}}
+	result, err := m.collection.UpdateOne(ctx, bson.M{"key": linkKey(name), "disabled": false}, update)
 	if err != nil {
 		return err
 	}
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+// RestoreLink implements Store.
+func (m *mongodb) RestoreLink(ctx context.Context, name string) error {
+	update := bson.M{"$set": bson.M{"disabled": false}, "$unset": bson.M{"disabled_at": ""}}
+	result, err := m.collection.UpdateOne(ctx, bson.M{"key": linkKey(name), "disabled": true}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
 		return ErrLinkNotFound
 	}
 	return nil
 }
 
+// PurgeLink implements Store.
+func (m *mongodb) PurgeLink(ctx context.Context, name string) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"key": linkKey(name), "disabled": true})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrLinkNotFound
+	}
+	return m.purgeRevisions(ctx, name)
+}
+
+// PurgeDisabledLinks implements Store. Links are deleted one at a time so
+// only the revisions of links that were actually deleted are removed.
+func (m *mongodb) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	filter := bson.M{"disabled": true, "disabled_at": bson.M{"$lt": before}}
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var links []Link
+	if err := cursor.All(ctx, &links); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, link := range links {
+		result, err := m.collection.DeleteOne(ctx, bson.M{"key": linkKey(link.Name), "disabled": true, "disabled_at": bson.M{"$lt": before}})
+		if err != nil {
+			return count, err
+		}
+		if result.DeletedCount == 0 {
+			continue
+		}
+		count++
+		if err := m.purgeRevisions(ctx, link.Name); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// purgeRevisions deletes the revisions of a purged link so a new link with
+// the same name starts a new history.
+func (m *mongodb) purgeRevisions(ctx context.Context, name string) error {
+	_, err := m.revisions.DeleteMany(ctx, bson.M{"link": name}, options.Delete().SetCollation(caseInsensitive))
+	return err
+}
+
+// GetDisabledLinks implements Store.
+func (m *mongodb) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"owners": bson.M{"$in": nonNilOwners(owners)}, "disabled": true}, options.Find().SetCollation(caseInsensitive).SetSort(bson.D{{Key: "disabled_at", Value: -1}}))
+	if err != nil {
+		return []Link{}, err
+	}
+	if cursor.RemainingBatchLength() == 0 {
+		return []Link{}, nil
+	}
+	var links []Link
+	err = cursor.All(ctx, &links)
+	if err != nil {
+		return []Link{}, err
+	}
+	return links, nil
+}
+
 // GetLinkByName implements Store.
 func (m *mongodb) GetLinkByName(ctx context.Context, name string) (Link, error) {
-	result := m.collection.FindOne(ctx, bson.M{"_id": name})
+	result := m.collection.FindOne(ctx, bson.M{"key": linkKey(name), "disabled": false})
 	if result.Err() != nil {
 		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
 			return Link{}, ErrLinkNotFound
@@ -107,7 +371,7 @@ func (m *mongodb) GetLinkByName(ctx context.Context, name string) (Link, error)
 
 // GetLinkByURL implements Store.
 func (m *mongodb) GetLinkByURL(ctx context.Context, url string) (Link, error) {
-	result := m.collection.FindOne(ctx, bson.M{"url": url})
+	result := m.collection.FindOne(ctx, bson.M{"url": url, "disabled": false})
 	if result.Err() != nil {
 		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
 			return Link{}, ErrLinkNotFound
@@ -123,8 +387,8 @@ func (m *mongodb) GetLinkByURL(ctx context.Context, url string) (Link, error) {
 }
 
 // GetOwnedLinks implements Store.
-func (m *mongodb) GetOwnedLinks(ctx context.Context, email string) ([]Link, error) {
-	cursor, err := m.collection.Find(ctx, bson.M{"created_by": email})
+func (m *mongodb) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"owners": bson.M{"$in": nonNilOwners(owners)}, "disabled": false}, options.Find().SetCollation(caseInsensitive))
 	if err != nil {
 		return []Link{}, err
 	}
@@ -141,7 +405,7 @@ func (m *mongodb) GetOwnedLinks(ctx context.Context, email string) ([]Link, erro
 
 // GetPopularLinks implements Store.
 func (m *mongodb) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
-	cursor, err := m.collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "views", Value: -1}}).SetLimit(int64(size)))
+	cursor, err := m.collection.Find(ctx, bson.M{"disabled": false}, options.Find().SetSort(bson.D{{Key: "views", Value: -1}}).SetLimit(int64(size)))
 	if err != nil {
 		return []Link{}, err
 	}
@@ -158,7 +422,7 @@ func (m *mongodb) GetPopularLinks(ctx context.Context, size int) ([]Link, error)
 
 // GetRecentLinks implements Store.
 func (m *mongodb) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
-	cursor, err := m.collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(int64(size)))
+	cursor, err := m.collection.Find(ctx, bson.M{"disabled": false}, options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(int64(size)))
 	if err != nil {
 		return []Link{}, err
 	}
@@ -175,21 +439,24 @@ func (m *mongodb) GetRecentLinks(ctx context.Context, size int) ([]Link, error)
 
 // IncrementLinkViews implements Store.
 func (m *mongodb) IncrementLinkViews(ctx context.Context, name string) error {
-	link, err := m.GetLinkByName(ctx, name)
+	return m.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store.
+func (m *mongodb) AddLinkViews(ctx context.Context, name string, delta int) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"key": linkKey(name), "disabled": false}, bson.M{"$inc": bson.M{"views": delta}})
 	if err != nil {
 		return err
 	}
-	link.Views++
-	err = m.updateLink(ctx, link)
-	if err != nil {
-		return err
+	if result.MatchedCount == 0 {
+		return ErrLinkNotFound
 	}
 	return nil
 }
 
 // QueryLinks implements Store.
 func (m *mongodb) QueryLinks(ctx context.Context, query string) ([]Link, error) {
-	filter := bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: fmt.Sprintf("\"%s\"", query)}}}}
+	filter := bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: fmt.Sprintf("\"%s\"", query)}}}, {Key: "disabled", Value: false}}
 	cursor, err := m.collection.Find(ctx, filter)
 	if err != nil {
 		return []Link{}, err
@@ -205,14 +472,294 @@ func (m *mongodb) QueryLinks(ctx context.Context, query string) ([]Link, error)
 	return links, nil
 }
 
-func (m *mongodb) updateLink(ctx context.Context, link Link) error {
-	// resp, err := m.collection.UpdateOne(ctx, bson.M{"_id": link.Name}, link)
-	resp := m.collection.FindOneAndReplace(ctx, bson.M{"_id": link.Name}, link)
-	if resp.Err() != nil {
-		if errors.Is(resp.Err(), mongo.ErrNoDocuments) {
-			return ErrLinkNotFound
+// WalkLinks implements Store.
+func (m *mongodb) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	cursor, err := m.collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var link Link
+		if err := cursor.Decode(&link); err != nil {
+			return err
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ImportLink implements Store.
+func (m *mongodb) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	link = link.withDefaultOwners()
+	if overwrite {
+		// a link whose name only differs in case is replaced as well, which
+		// can not be done in place since _id can not change
+		_, err := m.collection.DeleteOne(ctx, bson.M{"key": linkKey(link.Name), "_id": bson.M{"$ne": link.Name}})
+		if err != nil {
+			return err
+		}
+		_, err = m.collection.ReplaceOne(ctx, bson.M{"_id": link.Name}, newMongoLink(link), options.Replace().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrIDExists
+		}
+		return err
+	}
+	return m.insertLink(ctx, link)
+}
+
+// linkChangesPipeline leaves out updates that only change the view count so
+// redirects do not produce changes.
+var linkChangesPipeline = mongo.Pipeline{
+	{{Key: "$match", Value: bson.M{"$or": bson.A{
+		bson.M{"operationType": bson.M{"$in": bson.A{"insert", "replace", "delete"}}},
+		bson.M{"operationType": "update", "updateDescription.updatedFields.views": bson.M{"$exists": false}},
+	}}}},
+}
+
+type linkChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID string `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+// Subscribe implements Subscriber using a change stream, which requires a
+// replica set. Lost streams are resumed where they left off when possible.
+func (m *mongodb) Subscribe(ctx context.Context) (<-chan LinkChange, error) {
+	stream, err := m.collection.Watch(ctx, linkChangesPipeline)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan LinkChange)
+	go func() {
+		defer close(changes)
+		for {
+			m.forwardChangeStream(ctx, stream, changes)
+			resumeToken := stream.ResumeToken()
+			stream.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			for {
+				if !waitToRetry(ctx) {
+					return
+				}
+				if resumeToken != nil {
+					stream, err = m.collection.Watch(ctx, linkChangesPipeline, options.ChangeStream().SetResumeAfter(resumeToken))
+					if err == nil {
+						break
+					}
+					resumeToken = nil
+				}
+				stream, err = m.collection.Watch(ctx, linkChangesPipeline)
+				if err != nil {
+					continue
+				}
+				// changes since the stream was lost can not be replayed
+				if !sendChange(ctx, changes, LinkChange{}) {
+					stream.Close(context.Background())
+					return
+				}
+				break
+			}
+		}
+	}()
+	return changes, nil
+}
+
+func (m *mongodb) forwardChangeStream(ctx context.Context, stream *mongo.ChangeStream, changes chan<- LinkChange) {
+	for stream.Next(ctx) {
+		var event linkChangeEvent
+		if err := stream.Decode(&event); err != nil {
+			return
+		}
+		change := LinkChange{Name: event.DocumentKey.ID, Op: ChangeUpdated}
+		switch event.OperationType {
+		case "insert":
+			change.Op = ChangeCreated
+		case "delete":
+			change.Op = ChangeDeleted
 		}
-		return resp.Err()
+		if !sendChange(ctx, changes, change) {
+			return
+		}
+	}
+}
+
+// RecordClick implements Analytics.
+func (m *mongodb) RecordClick(ctx context.Context, click Click) error {
+	_, err := m.clicks.InsertOne(ctx, click)
+	return err
+}
+
+// RecordClicks implements Analytics.
+func (m *mongodb) RecordClicks(ctx context.Context, clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	docs := make([]any, 0, len(clicks))
+	for _, click := range clicks {
+		docs = append(docs, click)
+	}
+	_, err := m.clicks.InsertMany(ctx, docs)
+	return err
+}
+
+// PurgeClicks implements Analytics.
+func (m *mongodb) PurgeClicks(ctx context.Context, before time.Time) (int, error) {
+	result, err := m.clicks.DeleteMany(ctx, bson.M{"timestamp": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+// GetClickStats implements Analytics.
+func (m *mongodb) GetClickStats(ctx context.Context, name string, interval StatsInterval, since time.Time) ([]ClickBucket, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"link": name, "timestamp": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"$dateTrunc": bson.M{"date": "$timestamp", "unit": string(interval), "startOfWeek": "monday"}},
+			"clicks": bson.M{"$sum": 1},
+			"users":  bson.M{"$addToSet": "$user"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := m.clicks.Aggregate(ctx, pipeline)
+	if err != nil {
+		return []ClickBucket{}, err
+	}
+	var results []struct {
+		Start  time.Time `bson:"_id"`
+		Clicks int       `bson:"clicks"`
+		Users  []string  `bson:"users"`
+	}
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return []ClickBucket{}, err
+	}
+	buckets := make([]ClickBucket, 0, len(results))
+	for _, r := range results {
+		buckets = append(buckets, ClickBucket{Start: r.Start.UTC(), Clicks: r.Clicks, UniqueUsers: len(r.Users)})
+	}
+	return buckets, nil
+}
+
+// CreateToken implements Tokens.
+func (m *mongodb) CreateToken(ctx context.Context, token APIToken) error {
+	_, err := m.tokens.InsertOne(ctx, token)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrIDExists
+	}
+	return err
+}
+
+// GetTokenByHash implements Tokens.
+func (m *mongodb) GetTokenByHash(ctx context.Context, hash string) (APIToken, error) {
+	token := APIToken{}
+	err := m.tokens.FindOne(ctx, bson.M{"hash": hash}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return token, ErrTokenNotFound
+	}
+	return token, err
+}
+
+// GetTokens implements Tokens.
+func (m *mongodb) GetTokens(ctx context.Context, owner string) ([]APIToken, error) {
+	tokens := []APIToken{}
+	cursor, err := m.tokens.Find(ctx, bson.M{"owner": owner}, options.Find().SetCollation(caseInsensitive).SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return tokens, err
+	}
+	err = cursor.All(ctx, &tokens)
+	return tokens, err
+}
+
+// DeleteToken implements Tokens.
+func (m *mongodb) DeleteToken(ctx context.Context, owner string, id string) error {
+	result, err := m.tokens.DeleteOne(ctx, bson.M{"_id": id, "owner": owner}, options.Delete().SetCollation(caseInsensitive))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrTokenNotFound
 	}
 	return nil
 }
+
+// RecordAuditEvent implements Audit.
+func (m *mongodb) RecordAuditEvent(ctx context.Context, event AuditEvent) error {
+	_, err := m.audit.InsertOne(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrIDExists
+	}
+	return err
+}
+
+// GetAuditEvents implements Audit.
+func (m *mongodb) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	query := bson.M{}
+	if filter.Link != "" {
+		query["link"] = filter.Link
+	}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	between := bson.M{}
+	if !filter.Since.IsZero() {
+		between["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		between["$lt"] = filter.Until
+	}
+	if len(between) > 0 {
+		query["time"] = between
+	}
+	opts := options.Find().SetCollation(caseInsensitive).SetSort(bson.D{{Key: "time", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	events := []AuditEvent{}
+	cursor, err := m.audit.Find(ctx, query, opts)
+	if err != nil {
+		return events, err
+	}
+	err = cursor.All(ctx, &events)
+	return events, err
+}
+
+// AddRevision implements Revisions. Concurrent writers can pick the same
+// number, in which case the unique index rejects all but one and the others
+// try again.
+func (m *mongodb) AddRevision(ctx context.Context, revision LinkRevision) (LinkRevision, error) {
+	var err error
+	for attempt := 0; attempt < maxRevisionAttempts; attempt++ {
+		latest := LinkRevision{}
+		err = m.revisions.FindOne(ctx, bson.M{"link": revision.Link},
+			options.FindOne().SetCollation(caseInsensitive).SetSort(bson.D{{Key: "number", Value: -1}}),
+		).Decode(&latest)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return revision, err
+		}
+		revision.Number = latest.Number + 1
+		_, err = m.revisions.InsertOne(ctx, revision)
+		if !mongo.IsDuplicateKeyError(err) {
+			return revision, err
+		}
+	}
+	return revision, err
+}
+
+// GetRevisions implements Revisions.
+func (m *mongodb) GetRevisions(ctx context.Context, name string) ([]LinkRevision, error) {
+	revisions := []LinkRevision{}
+	cursor, err := m.revisions.Find(ctx, bson.M{"link": name}, options.Find().SetCollation(caseInsensitive).SetSort(bson.D{{Key: "number", Value: 1}}))
+	if err != nil {
+		return revisions, err
+	}
+	err = cursor.All(ctx, &revisions)
+	return revisions, err
+}
@@ -2,29 +2,70 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const linkChangesChannel = "link_changes"
+
+const postgresLinkColumns = `name, description, url, views, created_at, updated_at, created_by, disabled, disabled_at, owners`
+
+// postgresOwnedBy matches links owned by any entry of the lowercased text[] parameter.
+const postgresOwnedBy = `exists (select 1 from unnest(owners) owner where lower(owner) = any($1))`
+
 type postgres struct {
 	pool *pgxpool.Pool
 }
 
 var _ (Store) = (*postgres)(nil)
+var _ (Analytics) = (*postgres)(nil)
+var _ (Subscriber) = (*postgres)(nil)
+var _ (Tokens) = (*postgres)(nil)
+var _ (Audit) = (*postgres)(nil)
+var _ (Revisions) = (*postgres)(nil)
 
 func NewPostgresStore(ctx context.Context, user string, password string, host string, databaseName string) (*postgres, error) {
+	return openPostgres(ctx, user, password, host, databaseName, Options{})
+}
+
+func openPostgres(ctx context.Context, user string, password string, host string, databaseName string, opts Options) (*postgres, error) {
 	connectionString := fmt.Sprintf("postgres://%s:%s@%s/%s", user, password, host, databaseName)
-	pool, err := pgxpool.New(ctx, connectionString)
+	poolConfig, err := pgxpool.ParseConfig(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.Tracer = newPgxTracer(databaseName)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 	p := &postgres{
 		pool: pool,
 	}
+	if opts.MustExist || opts.ReadOnly {
+		var exists bool
+		err = pool.QueryRow(ctx, `select to_regclass('links') is not null`).Scan(&exists)
+		if err == nil && !exists {
+			err = fmt.Errorf("%w: no links table in database %s", ErrStoreNotFound, databaseName)
+		}
+		if err != nil {
+			p.Close(ctx)
+			return nil, err
+		}
+	}
+	if opts.ReadOnly {
+		return p, nil
+	}
 	err = p.ensureTable(ctx)
 	if err != nil {
 		p.Close(ctx)
@@ -33,6 +74,52 @@ func NewPostgresStore(ctx context.Context, user string, password string, host st
 	return p, nil
 }
 
+// pgxTracer records a span for every query, using the global tracer provider
+// so queries are only traced when tracing is enabled.
+type pgxTracer struct {
+	tracer   trace.Tracer
+	database string
+}
+
+var _ pgx.QueryTracer = (*pgxTracer)(nil)
+
+func newPgxTracer(database string) *pgxTracer {
+	return &pgxTracer{
+		tracer:   otel.GetTracerProvider().Tracer(tracerName),
+		database: database,
+	}
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t *pgxTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, _, _ := strings.Cut(strings.TrimSpace(data.SQL), " ")
+	operation = strings.ToLower(operation)
+	ctx, _ = t.tracer.Start(ctx, operation+" "+t.database,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBNamespace(t.database),
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(data.SQL),
+		),
+	)
+	return ctx
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (t *pgxTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if !errors.Is(data.Err, pgx.ErrNoRows) {
+		recordSpanError(span, data.Err)
+	}
+	span.End()
+}
+
+// Ping implements Store.
+func (p *postgres) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
 // Close implements Store.
 func (p *postgres) Close(ctx context.Context) error {
 	p.pool.Close()
@@ -41,85 +128,415 @@ func (p *postgres) Close(ctx context.Context) error {
 
 // CreateLink implements Store.
 func (p *postgres) CreateLink(ctx context.Context, link Link) error {
+	link = link.withDefaultOwners()
+	now := time.Now()
+	// links_lower_name_key rejects names that only differ in case
 	_, err := p.pool.Exec(ctx,
-		`insert into links(name, description, url, created_at, updated_at, created_by) values ($1, $2, $3, $4, $5, $6)`,
-		link.Name, link.Description, link.URL, link.Created, link.Created, link.CreatedBy,
+		`insert into links(name, description, url, created_at, updated_at, created_by, owners) values ($1, $2, $3, $4, $4, $5, $6)`,
+		link.Name, link.Description, link.URL, now, link.CreatedBy, nonNilOwners(link.Owners),
 	)
 	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		if pgErr.ConstraintName != "" {
-			return ErrIDExists
-		}
+	if errors.As(err, &pgErr) && pgErr.ConstraintName != "" {
+		return ErrIDExists
 	}
+	return err
+}
+
+// UpdateLink implements Store.
+func (p *postgres) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	resp, err := p.pool.Exec(ctx,
+		`update links set description = coalesce($1, description), url = coalesce($2, url), owners = coalesce($3, owners), updated_at = $4 where lower(name) = lower($5) and not disabled`,
+		patch.Description, patch.URL, patch.Owners, time.Now(), name,
+	)
 	if err != nil {
 		return err
 	}
+	if resp.RowsAffected() == 0 {
+		return ErrLinkNotFound
+	}
 	return nil
 }
 
 // DisableLink implements Store.
 func (p *postgres) DisableLink(ctx context.Context, name string) error {
-	resp, err := p.pool.Exec(ctx, `delete from links where name = $1`, name)
-	if err != nil {
-		return err
-	}
-	if resp.RowsAffected() == 0 {
+	return p.execSingle(ctx, `update links set disabled = true, disabled_at = $1 where lower(name) = lower($2) and not disabled`, time.Now(), name)
+}
+
+// RestoreLink implements Store.
+func (p *postgres) RestoreLink(ctx context.Context, name string) error {
+	return p.execSingle(ctx, `update links set disabled = false, disabled_at = null where lower(name) = lower($1) and disabled`, name)
+}
+
+// PurgeLink implements Store.
+func (p *postgres) PurgeLink(ctx context.Context, name string) error {
+	count, err := p.purgeLinks(ctx, `lower(name) = lower($1) and disabled`, name)
+	if err == nil && count == 0 {
 		return ErrLinkNotFound
 	}
-	return nil
+	return err
+}
+
+// PurgeDisabledLinks implements Store.
+func (p *postgres) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	return p.purgeLinks(ctx, `disabled and disabled_at < $1`, before)
+}
+
+// purgeLinks deletes the links matching where along with their revisions, so
+// a new link with the same name starts a new history.
+func (p *postgres) purgeLinks(ctx context.Context, where string, args ...any) (int, error) {
+	var count int
+	err := p.pool.QueryRow(ctx,
+		`with purged as (delete from links where `+where+` returning name),
+		revisions as (delete from link_revisions where lower(link) in (select lower(name) from purged))
+		select count(*) from purged`,
+		args...,
+	).Scan(&count)
+	return count, err
+}
+
+// GetDisabledLinks implements Store.
+func (p *postgres) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	return p.getMultipleResults(ctx, `select `+postgresLinkColumns+` from links where `+postgresOwnedBy+` and disabled order by disabled_at desc`, lowerOwners(owners))
 }
 
 // GetLinkByName implements Store.
 func (p *postgres) GetLinkByName(ctx context.Context, name string) (Link, error) {
-	return p.getSingleResult(ctx, `select * from links where name = $1`, name)
+	return p.getSingleResult(ctx, `select `+postgresLinkColumns+` from links where lower(name) = lower($1) and not disabled`, name)
 }
 
 // GetLinkByURL implements Store.
 func (p *postgres) GetLinkByURL(ctx context.Context, url string) (Link, error) {
-	return p.getSingleResult(ctx, `select * from links where url = $1`, url)
+	return p.getSingleResult(ctx, `select `+postgresLinkColumns+` from links where url = $1 and not disabled`, url)
 }
 
 // GetOwnedLinks implements Store.
-func (p *postgres) GetOwnedLinks(ctx context.Context, email string) ([]Link, error) {
-	return p.getMultipleResults(ctx, `select * from links where created_by = $1`, email)
+func (p *postgres) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
+	return p.getMultipleResults(ctx, `select `+postgresLinkColumns+` from links where `+postgresOwnedBy+` and not disabled`, lowerOwners(owners))
 }
 
 // GetPopularLinks implements Store.
 func (p *postgres) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
-	return p.getMultipleResults(ctx, fmt.Sprintf("select * from links order by views desc limit %d", size))
+	return p.getMultipleResults(ctx, fmt.Sprintf("select "+postgresLinkColumns+" from links where not disabled order by views desc limit %d", size))
 }
 
 // GetRecentLinks implements Store.
 func (p *postgres) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
-	return p.getMultipleResults(ctx, fmt.Sprintf("select * from links order by updated_at desc limit %d", size))
+	return p.getMultipleResults(ctx, fmt.Sprintf("select "+postgresLinkColumns+" from links where not disabled order by updated_at desc limit %d", size))
 }
 
 // IncrementLinkViews implements Store.
 func (p *postgres) IncrementLinkViews(ctx context.Context, name string) error {
-	link, err := p.GetLinkByName(ctx, name)
+	return p.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store.
+func (p *postgres) AddLinkViews(ctx context.Context, name string, delta int) error {
+	return p.execSingle(ctx, `update links set views = views + $1 where lower(name) = lower($2) and not disabled`, delta, name)
+}
+
+// QueryLinks implements Store.
+func (p *postgres) QueryLinks(ctx context.Context, query string) ([]Link, error) {
+	return p.getMultipleResults(ctx, `select `+postgresLinkColumns+` from links where not disabled and (name ilike '%' || $1 || '%' or description ilike '%' || $1 || '%') order by views desc`, query)
+}
+
+// WalkLinks implements Store.
+func (p *postgres) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	rows, err := p.pool.Query(ctx, `select `+postgresLinkColumns+` from links order by name`)
 	if err != nil {
-		return err
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link Link
+		err = rows.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled, &link.DisabledAt, &link.Owners)
+		if err != nil {
+			return fmt.Errorf("failed while scanning: %w", err)
+		}
+		if err = fn(link); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportLink implements Store.
+func (p *postgres) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	link = link.withDefaultOwners()
+	query := `insert into links(name, description, url, views, created_at, updated_at, created_by, disabled, disabled_at, owners)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if overwrite {
+		query += ` on conflict ((lower(name))) do update set name = excluded.name, description = excluded.description, url = excluded.url, views = excluded.views,
+			created_at = excluded.created_at, updated_at = excluded.updated_at, created_by = excluded.created_by,
+			disabled = excluded.disabled, disabled_at = excluded.disabled_at, owners = excluded.owners`
+	}
+	_, err := p.pool.Exec(ctx, query,
+		link.Name, link.Description, link.URL, link.Views, link.Created, link.Updated, link.CreatedBy, link.Disabled, link.DisabledAt, nonNilOwners(link.Owners),
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName != "" {
+		return ErrIDExists
+	}
+	return err
+}
+
+// Subscribe implements Subscriber using LISTEN on a dedicated connection,
+// which is reconnected if it is lost.
+func (p *postgres) Subscribe(ctx context.Context) (<-chan LinkChange, error) {
+	conn, err := p.listen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan LinkChange)
+	go func() {
+		defer close(changes)
+		for {
+			err := p.forwardNotifications(ctx, conn, changes)
+			conn.Conn().Close(context.Background())
+			conn.Release()
+			if ctx.Err() != nil {
+				return
+			}
+			for {
+				if !waitToRetry(ctx) {
+					return
+				}
+				conn, err = p.listen(ctx)
+				if err == nil {
+					break
+				}
+			}
+			if !sendChange(ctx, changes, LinkChange{}) {
+				conn.Conn().Close(context.Background())
+				conn.Release()
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+func (p *postgres) listen(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Exec(ctx, `listen `+linkChangesChannel)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (p *postgres) forwardNotifications(ctx context.Context, conn *pgxpool.Conn, changes chan<- LinkChange) error {
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var change LinkChange
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			return fmt.Errorf("invalid link change notification: %w", err)
+		}
+		if change.Op == "insert" {
+			change.Op = ChangeCreated
+		}
+		if !sendChange(ctx, changes, change) {
+			return ctx.Err()
+		}
+	}
+}
+
+const postgresInsertClick = `insert into clicks(link, clicked_at, referrer, username) values ($1, $2, nullif($3, ''), nullif($4, ''))`
+
+// RecordClick implements Analytics.
+func (p *postgres) RecordClick(ctx context.Context, click Click) error {
+	_, err := p.pool.Exec(ctx, postgresInsertClick, click.Link, click.Timestamp, click.Referrer, click.User)
+	return err
+}
+
+// RecordClicks implements Analytics. The clicks are sent as one batch.
+func (p *postgres) RecordClicks(ctx context.Context, clicks []Click) error {
+	batch := &pgx.Batch{}
+	for _, click := range clicks {
+		batch.Queue(postgresInsertClick, click.Link, click.Timestamp, click.Referrer, click.User)
+	}
+	return p.pool.SendBatch(ctx, batch).Close()
+}
+
+// PurgeClicks implements Analytics.
+func (p *postgres) PurgeClicks(ctx context.Context, before time.Time) (int, error) {
+	resp, err := p.pool.Exec(ctx, `delete from clicks where clicked_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.RowsAffected()), nil
+}
+
+// GetClickStats implements Analytics.
+func (p *postgres) GetClickStats(ctx context.Context, name string, interval StatsInterval, since time.Time) ([]ClickBucket, error) {
+	buckets := []ClickBucket{}
+	rows, err := p.pool.Query(ctx,
+		`select date_trunc($1, clicked_at at time zone 'UTC') as start, count(*), count(distinct username)
+		from clicks where link = $2 and clicked_at >= $3 group by start order by start`,
+		string(interval), name, since,
+	)
+	if err != nil {
+		return buckets, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket ClickBucket
+		err = rows.Scan(&bucket.Start, &bucket.Clicks, &bucket.UniqueUsers)
+		if err != nil {
+			return buckets, fmt.Errorf("failed while scanning: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// CreateToken implements Tokens.
+func (p *postgres) CreateToken(ctx context.Context, token APIToken) error {
+	_, err := p.pool.Exec(ctx,
+		`insert into api_tokens(id, hash, owner, name, scope, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7)`,
+		token.ID, token.Hash, token.Owner, token.Name, string(token.Scope), token.Created, token.Expires,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName != "" {
+		return ErrIDExists
+	}
+	return err
+}
+
+// GetTokenByHash implements Tokens.
+func (p *postgres) GetTokenByHash(ctx context.Context, hash string) (APIToken, error) {
+	tokens, err := p.getTokens(ctx, `select `+tokenColumns+` from api_tokens where hash = $1`, hash)
+	if err != nil {
+		return APIToken{}, err
 	}
-	views := link.Views + 1
-	resp, err := p.pool.Exec(ctx, `update links set views=$1 where name=$2`, views, name)
+	if len(tokens) == 0 {
+		return APIToken{}, ErrTokenNotFound
+	}
+	return tokens[0], nil
+}
+
+// GetTokens implements Tokens.
+func (p *postgres) GetTokens(ctx context.Context, owner string) ([]APIToken, error) {
+	return p.getTokens(ctx, `select `+tokenColumns+` from api_tokens where lower(owner) = lower($1) order by created_at desc`, owner)
+}
+
+// DeleteToken implements Tokens.
+func (p *postgres) DeleteToken(ctx context.Context, owner string, id string) error {
+	resp, err := p.pool.Exec(ctx, `delete from api_tokens where id = $1 and lower(owner) = lower($2)`, id, owner)
 	if err != nil {
 		return err
 	}
 	if resp.RowsAffected() == 0 {
-		return ErrLinkNotFound
+		return ErrTokenNotFound
 	}
 	return nil
 }
 
-// QueryLinks implements Store.
-func (p *postgres) QueryLinks(ctx context.Context, query string) ([]Link, error) {
-	return p.getMultipleResults(ctx, `select * from links where name ilike '%' || $1 || '%' or description ilike '%' || $1 || '%' order by views desc`, query)
+func (p *postgres) getTokens(ctx context.Context, query string, args ...any) ([]APIToken, error) {
+	tokens := []APIToken{}
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return tokens, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token APIToken
+		var scope string
+		err = rows.Scan(&token.ID, &token.Hash, &token.Owner, &token.Name, &scope, &token.Created, &token.Expires)
+		if err != nil {
+			return tokens, fmt.Errorf("failed while scanning: %w", err)
+		}
+		token.Scope = TokenScope(scope)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RecordAuditEvent implements Audit.
+func (p *postgres) RecordAuditEvent(ctx context.Context, event AuditEvent) error {
+	_, err := p.pool.Exec(ctx,
+		`insert into audit_events(`+auditColumns+`) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		event.ID, event.Time, event.Actor, string(event.Action), event.Link,
+		event.Before, event.After, event.ClientIP, event.AdminOverride,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName != "" {
+		return ErrIDExists
+	}
+	return err
+}
+
+// GetAuditEvents implements Audit.
+func (p *postgres) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	query, args := auditQuery(filter, "lower(%s) = lower($%s)")
+	events := []AuditEvent{}
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return events, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event AuditEvent
+		var action string
+		err = rows.Scan(&event.ID, &event.Time, &event.Actor, &action, &event.Link, &event.Before, &event.After, &event.ClientIP, &event.AdminOverride)
+		if err != nil {
+			return events, fmt.Errorf("failed while scanning: %w", err)
+		}
+		event.Action = AuditAction(action)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// AddRevision implements Revisions. Concurrent writers can pick the same
+// number, in which case the unique index rejects all but one and the others
+// try again.
+func (p *postgres) AddRevision(ctx context.Context, revision LinkRevision) (LinkRevision, error) {
+	var err error
+	for attempt := 0; attempt < maxRevisionAttempts; attempt++ {
+		err = p.pool.QueryRow(ctx,
+			`insert into link_revisions(`+revisionColumns+`)
+			select $1, coalesce(max(number), 0) + 1, $2, $3, $4, $5, $6 from link_revisions where lower(link) = lower($1)
+			returning number`,
+			revision.Link, revision.URL, revision.Description, revision.Author, revision.Created, revision.RolledBackFrom,
+		).Scan(&revision.Number)
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.ConstraintName == "" {
+			return revision, err
+		}
+	}
+	return revision, err
+}
+
+// GetRevisions implements Revisions.
+func (p *postgres) GetRevisions(ctx context.Context, name string) ([]LinkRevision, error) {
+	revisions := []LinkRevision{}
+	rows, err := p.pool.Query(ctx, `select `+revisionColumns+` from link_revisions where lower(link) = lower($1) order by number`, name)
+	if err != nil {
+		return revisions, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var revision LinkRevision
+		err = rows.Scan(&revision.Link, &revision.Number, &revision.URL, &revision.Description, &revision.Author, &revision.Created, &revision.RolledBackFrom)
+		if err != nil {
+			return revisions, fmt.Errorf("failed while scanning: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
 }
 
 func (p *postgres) getSingleResult(ctx context.Context, query string, args ...any) (Link, error) {
 	row := p.pool.QueryRow(ctx, query, args...)
 	link := Link{}
-	err := row.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled)
+	err := row.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled, &link.DisabledAt, &link.Owners)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return link, ErrLinkNotFound
 	}
@@ -138,7 +555,7 @@ func (p *postgres) getMultipleResults(ctx context.Context, query string, args ..
 	defer rows.Close()
 	for rows.Next() {
 		var link Link
-		err = rows.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled)
+		err = rows.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled, &link.DisabledAt, &link.Owners)
 		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 			return links, fmt.Errorf("failed while scanning: %w", err)
 		}
@@ -156,10 +573,147 @@ func (p *postgres) ensureTable(ctx context.Context) error {
 		created_at timestamptz not null,
 		updated_at timestamptz not null,
 		created_by text not null,
-		disabled bool default false
+		disabled bool default false,
+		disabled_at timestamptz
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `alter table links add column if not exists disabled_at timestamptz`)
+	if err != nil {
+		return err
+	}
+	// links created before links could have several owners are owned by their creator
+	_, err = p.pool.Exec(ctx, `alter table links add column if not exists owners text[] not null default '{}'`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `update links set owners = array[created_by] where cardinality(owners) = 0 and created_by <> ''`)
+	if err != nil {
+		return err
+	}
+	// owners are matched case-insensitively, which a gin index on owners can
+	// not serve
+	_, err = p.pool.Exec(ctx, `drop index if exists links_owners`)
+	if err != nil {
+		return err
+	}
+	// names are unique regardless of case, which the primary key can not
+	// enforce, so the unique index on lower(name) replaces the plain one
+	_, err = p.pool.Exec(ctx, `create unique index if not exists links_lower_name_key on links (lower(name))`)
+	if err != nil {
+		return fmt.Errorf("failed to create unique index on lower(name), rename links whose names only differ in case: %w", err)
+	}
+	_, err = p.pool.Exec(ctx, `drop index if exists links_lower_name`)
+	if err != nil {
+		return err
+	}
+	// view counts are left out of the trigger so redirects do not notify
+	_, err = p.pool.Exec(ctx, `create or replace function notify_link_change() returns trigger as $$
+		begin
+			perform pg_notify('`+linkChangesChannel+`', json_build_object('name', coalesce(new.name, old.name), 'op', lower(tg_op))::text);
+			return null;
+		end;
+		$$ language plpgsql`)
+	if err != nil {
+		return err
+	}
+	// the trigger is recreated so databases from before owners existed also notify owner changes
+	_, err = p.pool.Exec(ctx, `do $$
+		begin
+			drop trigger if exists links_notify on links;
+			create trigger links_notify after insert or delete or update of name, description, url, created_by, disabled, owners on links
+				for each row execute function notify_link_change();
+		end;
+		$$`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `create table if not exists clicks (
+		link text not null,
+		clicked_at timestamptz not null,
+		referrer text,
+		username text
 	)`)
 	if err != nil {
 		return err
 	}
+	_, err = p.pool.Exec(ctx, `create index if not exists clicks_link_clicked_at on clicks (link, clicked_at)`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `create index if not exists clicks_clicked_at on clicks (clicked_at)`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `create table if not exists api_tokens (
+		id text not null primary key,
+		hash text not null unique,
+		owner text not null,
+		name text not null default '',
+		scope text not null,
+		created_at timestamptz not null,
+		expires_at timestamptz
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `create index if not exists api_tokens_lower_owner on api_tokens (lower(owner))`)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `create table if not exists audit_events (
+		id text not null primary key,
+		occurred_at timestamptz not null,
+		actor text not null,
+		action text not null,
+		link text not null,
+		link_before jsonb,
+		link_after jsonb,
+		client_ip text not null default '',
+		admin_override boolean not null default false
+	)`)
+	if err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		`create index if not exists audit_events_lower_link_occurred_at on audit_events (lower(link), occurred_at)`,
+		`create index if not exists audit_events_lower_actor_occurred_at on audit_events (lower(actor), occurred_at)`,
+		`create index if not exists audit_events_occurred_at on audit_events (occurred_at)`,
+		`create table if not exists link_revisions (
+			link text not null,
+			number integer not null,
+			url text not null,
+			description text not null default '',
+			author text not null default '',
+			created_at timestamptz not null,
+			rolled_back_from integer not null default 0
+		)`,
+		`create unique index if not exists link_revisions_lower_link_number on link_revisions (lower(link), number)`,
+	} {
+		_, err = p.pool.Exec(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (p *postgres) execSingle(ctx context.Context, query string, args ...any) error {
+	resp, err := p.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if resp.RowsAffected() == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+func lowerOwners(owners []string) []string {
+	lowered := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		lowered = append(lowered, strings.ToLower(owner))
+	}
+	return lowered
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxRevisionAttempts is how often AddRevision retries when another writer
+// took the next revision number first.
+const maxRevisionAttempts = 5
+
+const revisionColumns = "link, number, url, description, author, created_at, rolled_back_from"
+
+// LinkRevision is the URL and description of a link after a change. Revisions
+// are numbered from 1 for each link name.
+type LinkRevision struct {
+	Link        string    `json:"link" bson:"link"`
+	Number      int       `json:"number" bson:"number"`
+	URL         string    `json:"url" bson:"url"`
+	Description string    `json:"description" bson:"description"`
+	Author      string    `json:"author" bson:"author"`
+	Created     time.Time `json:"created_at" bson:"created_at"`
+	// RolledBackFrom is the revision restored by a rollback.
+	RolledBackFrom int `json:"rolled_back_from,omitempty" bson:"rolled_back_from,omitempty"`
+}
+
+// Revisions stores the history of each link's URL and description.
+type Revisions interface {
+	// AddRevision stores revision as the next revision of its link, ignoring
+	// revision.Number, and returns it with its number set.
+	AddRevision(ctx context.Context, revision LinkRevision) (LinkRevision, error)
+	// GetRevisions returns the revisions of the link called name, oldest first.
+	GetRevisions(ctx context.Context, name string) ([]LinkRevision, error)
+}
+
+// linkRevisions returns the revisions of the link called name, oldest first.
+func linkRevisions(revisions []LinkRevision, name string) []LinkRevision {
+	matched := []LinkRevision{}
+	for _, revision := range revisions {
+		if strings.EqualFold(revision.Link, name) {
+			matched = append(matched, revision)
+		}
+	}
+	slices.SortFunc(matched, func(a LinkRevision, b LinkRevision) int {
+		return a.Number - b.Number
+	})
+	return matched
+}
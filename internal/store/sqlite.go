@@ -0,0 +1,689 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	sqlite3 "modernc.org/sqlite"
+	sqlite3lib "modernc.org/sqlite/lib"
+)
+
+// ftsMinQueryLength is the shortest query the trigram full-text index can match.
+const ftsMinQueryLength = 3
+
+// sqliteOwnedBy matches links owned by any entry of a JSON array parameter,
+// using the link_owners table so the lookup is indexed.
+const sqliteOwnedBy = `links.name in (select name from link_owners where owner in (select value from json_each($1)))`
+
+// sqliteOwners stores Link.Owners as a JSON array. A nil list is stored as
+// NULL, which UpdateLink uses to leave the owners unchanged.
+type sqliteOwners []string
+
+func (o *sqliteOwners) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*o = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), (*[]string)(o))
+	case []byte:
+		return json.Unmarshal(v, (*[]string)(o))
+	}
+	return fmt.Errorf("unsupported owners type %T", src)
+}
+
+func (o sqliteOwners) Value() (driver.Value, error) {
+	if o == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]string(o))
+	return string(data), err
+}
+
+// sqliteSnapshot stores the link snapshots of an AuditEvent as JSON, with
+// NULL for a missing snapshot.
+type sqliteSnapshot struct {
+	link **Link
+}
+
+func (s sqliteSnapshot) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s.link = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s.link)
+	case []byte:
+		return json.Unmarshal(v, s.link)
+	}
+	return fmt.Errorf("unsupported snapshot type %T", src)
+}
+
+func (s sqliteSnapshot) Value() (driver.Value, error) {
+	if *s.link == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(*s.link)
+	return string(data), err
+}
+
+type sqlite struct {
+	db *sql.DB
+}
+
+var _ (Store) = (*sqlite)(nil)
+var _ (Analytics) = (*sqlite)(nil)
+var _ (Tokens) = (*sqlite)(nil)
+var _ (Audit) = (*sqlite)(nil)
+var _ (Revisions) = (*sqlite)(nil)
+
+func NewSQLiteStore(ctx context.Context, path string) (*sqlite, error) {
+	return openSQLite(ctx, path, Options{})
+}
+
+func openSQLite(ctx context.Context, path string, opts Options) (*sqlite, error) {
+	if opts.MustExist || opts.ReadOnly {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrStoreNotFound, path)
+		}
+	}
+	dsn := fmt.Sprintf("file:%s?_time_format=sqlite&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlite{
+		db: db,
+	}
+	if opts.ReadOnly {
+		return s, nil
+	}
+	err = s.ensureTables(ctx)
+	if err != nil {
+		s.Close(ctx)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Ping implements Store.
+func (s *sqlite) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close implements Store.
+func (s *sqlite) Close(ctx context.Context) error {
+	return s.db.Close()
+}
+
+// CreateLink implements Store.
+func (s *sqlite) CreateLink(ctx context.Context, link Link) error {
+	link = link.withDefaultOwners()
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(ctx,
+		`insert into links(name, description, url, views, created_at, updated_at, created_by, owners) values ($1, $2, $3, $4, $5, $5, $6, $7)`,
+		link.Name, link.Description, link.URL, link.Views, now, link.CreatedBy, sqliteOwners(nonNilOwners(link.Owners)),
+	)
+	var sqliteErr *sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3lib.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return ErrIDExists
+	}
+	return err
+}
+
+// UpdateLink implements Store.
+func (s *sqlite) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	return s.execSingle(ctx,
+		`update links set description = coalesce($1, description), url = coalesce($2, url), owners = coalesce($3, owners), updated_at = $4 where name = $5 and not disabled`,
+		patch.Description, patch.URL, sqliteOwners(patch.Owners), time.Now().UTC(), name,
+	)
+}
+
+// DisableLink implements Store.
+func (s *sqlite) DisableLink(ctx context.Context, name string) error {
+	return s.execSingle(ctx, `update links set disabled = true, disabled_at = $1 where name = $2 and not disabled`, time.Now().UTC(), name)
+}
+
+// RestoreLink implements Store.
+func (s *sqlite) RestoreLink(ctx context.Context, name string) error {
+	return s.execSingle(ctx, `update links set disabled = false, disabled_at = null where name = $1 and disabled`, name)
+}
+
+// PurgeLink implements Store.
+func (s *sqlite) PurgeLink(ctx context.Context, name string) error {
+	count, err := s.purgeLinks(ctx, `name = $1 and disabled`, name)
+	if err == nil && count == 0 {
+		return ErrLinkNotFound
+	}
+	return err
+}
+
+// PurgeDisabledLinks implements Store.
+func (s *sqlite) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	return s.purgeLinks(ctx, `disabled and disabled_at < $1`, before.UTC())
+}
+
+// purgeLinks deletes the links matching where along with their revisions, so
+// a new link with the same name starts a new history.
+func (s *sqlite) purgeLinks(ctx context.Context, where string, args ...any) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `delete from link_revisions where link in (select name from links where `+where+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := tx.ExecContext(ctx, `delete from links where `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := resp.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(count), tx.Commit()
+}
+
+// GetDisabledLinks implements Store.
+func (s *sqlite) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	return s.getMultipleResults(ctx, `select `+sqliteLinkColumns+` from links where `+sqliteOwnedBy+` and disabled order by disabled_at desc`, sqliteOwners(owners))
+}
+
+// GetLinkByName implements Store.
+func (s *sqlite) GetLinkByName(ctx context.Context, name string) (Link, error) {
+	return s.getSingleResult(ctx, `select `+sqliteLinkColumns+` from links where name = $1 and not disabled`, name)
+}
+
+// GetLinkByURL implements Store.
+func (s *sqlite) GetLinkByURL(ctx context.Context, url string) (Link, error) {
+	return s.getSingleResult(ctx, `select `+sqliteLinkColumns+` from links where url = $1 collate nocase and not disabled`, url)
+}
+
+// GetOwnedLinks implements Store.
+func (s *sqlite) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
+	return s.getMultipleResults(ctx, `select `+sqliteLinkColumns+` from links where `+sqliteOwnedBy+` and not disabled`, sqliteOwners(owners))
+}
+
+// GetPopularLinks implements Store.
+func (s *sqlite) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
+	return s.getMultipleResults(ctx, `select `+sqliteLinkColumns+` from links where not disabled order by views desc limit $1`, size)
+}
+
+// GetRecentLinks implements Store.
+func (s *sqlite) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
+	return s.getMultipleResults(ctx, `select `+sqliteLinkColumns+` from links where not disabled order by updated_at desc limit $1`, size)
+}
+
+// IncrementLinkViews implements Store.
+func (s *sqlite) IncrementLinkViews(ctx context.Context, name string) error {
+	return s.AddLinkViews(ctx, name, 1)
+}
+
+// AddLinkViews implements Store.
+func (s *sqlite) AddLinkViews(ctx context.Context, name string, delta int) error {
+	return s.execSingle(ctx, `update links set views = views + $1 where name = $2 and not disabled`, delta, name)
+}
+
+// QueryLinks implements Store. Queries long enough for the trigram index use
+// full-text search; shorter ones fall back to a substring scan.
+func (s *sqlite) QueryLinks(ctx context.Context, query string) ([]Link, error) {
+	if len([]rune(query)) < ftsMinQueryLength {
+		return s.getMultipleResults(ctx,
+			`select `+sqliteLinkColumns+` from links where not disabled and (instr(lower(name), lower($1)) > 0 or instr(lower(description), lower($1)) > 0) order by views desc`,
+			query,
+		)
+	}
+	return s.getMultipleResults(ctx,
+		`select `+sqliteLinkColumns+` from links join links_fts on links.rowid = links_fts.rowid
+		where links_fts match $1 and not links.disabled order by links.views desc`,
+		`"`+strings.ReplaceAll(query, `"`, `""`)+`"`,
+	)
+}
+
+// WalkLinks implements Store.
+func (s *sqlite) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	rows, err := s.db.QueryContext(ctx, `select `+sqliteLinkColumns+` from links order by name`)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link Link
+		err = rows.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled, &link.DisabledAt, (*sqliteOwners)(&link.Owners))
+		if err != nil {
+			return fmt.Errorf("failed while scanning: %w", err)
+		}
+		if err = fn(link); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportLink implements Store.
+func (s *sqlite) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	link = link.withDefaultOwners()
+	query := `insert into links(name, description, url, views, created_at, updated_at, created_by, disabled, disabled_at, owners)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if overwrite {
+		query += ` on conflict (name) do update set name = excluded.name, description = excluded.description, url = excluded.url, views = excluded.views,
+			created_at = excluded.created_at, updated_at = excluded.updated_at, created_by = excluded.created_by,
+			disabled = excluded.disabled, disabled_at = excluded.disabled_at, owners = excluded.owners`
+	}
+	var disabledAt *time.Time
+	if link.DisabledAt != nil {
+		t := link.DisabledAt.UTC()
+		disabledAt = &t
+	}
+	_, err := s.db.ExecContext(ctx, query,
+		link.Name, link.Description, link.URL, link.Views, link.Created.UTC(), link.Updated.UTC(), link.CreatedBy, link.Disabled, disabledAt, sqliteOwners(nonNilOwners(link.Owners)),
+	)
+	var sqliteErr *sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3lib.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return ErrIDExists
+	}
+	return err
+}
+
+const sqliteInsertClick = `insert into clicks(link, clicked_at, referrer, username) values ($1, $2, nullif($3, ''), nullif($4, ''))`
+
+// RecordClick implements Analytics.
+func (s *sqlite) RecordClick(ctx context.Context, click Click) error {
+	_, err := s.db.ExecContext(ctx, sqliteInsertClick, click.Link, click.Timestamp.UTC(), click.Referrer, click.User)
+	return err
+}
+
+// RecordClicks implements Analytics. The clicks are inserted in a single
+// transaction.
+func (s *sqlite) RecordClicks(ctx context.Context, clicks []Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, click := range clicks {
+		_, err := tx.ExecContext(ctx, sqliteInsertClick, click.Link, click.Timestamp.UTC(), click.Referrer, click.User)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PurgeClicks implements Analytics.
+func (s *sqlite) PurgeClicks(ctx context.Context, before time.Time) (int, error) {
+	resp, err := s.db.ExecContext(ctx, `delete from clicks where clicked_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	count, err := resp.RowsAffected()
+	return int(count), err
+}
+
+// GetClickStats implements Analytics.
+func (s *sqlite) GetClickStats(ctx context.Context, name string, interval StatsInterval, since time.Time) ([]ClickBucket, error) {
+	// weeks start on the Monday on or before the click
+	start := `date(clicked_at)`
+	if interval == StatsIntervalWeek {
+		start = `date(clicked_at, '-6 days', 'weekday 1')`
+	}
+	buckets := []ClickBucket{}
+	rows, err := s.db.QueryContext(ctx,
+		`select `+start+` as start, count(*), count(distinct username)
+		from clicks where link = $1 and clicked_at >= $2 group by start order by start`,
+		name, since.UTC(),
+	)
+	if err != nil {
+		return buckets, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket ClickBucket
+		var day string
+		err = rows.Scan(&day, &bucket.Clicks, &bucket.UniqueUsers)
+		if err != nil {
+			return buckets, fmt.Errorf("failed while scanning: %w", err)
+		}
+		bucket.Start, err = time.Parse(time.DateOnly, day)
+		if err != nil {
+			return buckets, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// CreateToken implements Tokens.
+func (s *sqlite) CreateToken(ctx context.Context, token APIToken) error {
+	_, err := s.db.ExecContext(ctx,
+		`insert into api_tokens(id, hash, owner, name, scope, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7)`,
+		token.ID, token.Hash, token.Owner, token.Name, token.Scope, token.Created.UTC(), token.Expires,
+	)
+	var sqliteErr *sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3lib.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3lib.SQLITE_CONSTRAINT_UNIQUE) {
+		return ErrIDExists
+	}
+	return err
+}
+
+// GetTokenByHash implements Tokens.
+func (s *sqlite) GetTokenByHash(ctx context.Context, hash string) (APIToken, error) {
+	tokens, err := s.getTokens(ctx, `select `+tokenColumns+` from api_tokens where hash = $1`, hash)
+	if err != nil {
+		return APIToken{}, err
+	}
+	if len(tokens) == 0 {
+		return APIToken{}, ErrTokenNotFound
+	}
+	return tokens[0], nil
+}
+
+// GetTokens implements Tokens.
+func (s *sqlite) GetTokens(ctx context.Context, owner string) ([]APIToken, error) {
+	return s.getTokens(ctx, `select `+tokenColumns+` from api_tokens where owner = $1 collate nocase order by created_at desc`, owner)
+}
+
+// DeleteToken implements Tokens.
+func (s *sqlite) DeleteToken(ctx context.Context, owner string, id string) error {
+	resp, err := s.db.ExecContext(ctx, `delete from api_tokens where id = $1 and owner = $2 collate nocase`, id, owner)
+	if err != nil {
+		return err
+	}
+	count, err := resp.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (s *sqlite) getTokens(ctx context.Context, query string, args ...any) ([]APIToken, error) {
+	tokens := []APIToken{}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return tokens, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token APIToken
+		err = rows.Scan(&token.ID, &token.Hash, &token.Owner, &token.Name, &token.Scope, &token.Created, &token.Expires)
+		if err != nil {
+			return tokens, fmt.Errorf("failed while scanning: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RecordAuditEvent implements Audit.
+func (s *sqlite) RecordAuditEvent(ctx context.Context, event AuditEvent) error {
+	_, err := s.db.ExecContext(ctx,
+		`insert into audit_events(`+auditColumns+`) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		event.ID, event.Time.UTC(), event.Actor, event.Action, event.Link,
+		sqliteSnapshot{&event.Before}, sqliteSnapshot{&event.After}, event.ClientIP, event.AdminOverride,
+	)
+	var sqliteErr *sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3lib.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return ErrIDExists
+	}
+	return err
+}
+
+// GetAuditEvents implements Audit.
+func (s *sqlite) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	query, args := auditQuery(filter, "%s = $%s collate nocase")
+	events := []AuditEvent{}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return events, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event AuditEvent
+		err = rows.Scan(&event.ID, &event.Time, &event.Actor, &event.Action, &event.Link,
+			sqliteSnapshot{&event.Before}, sqliteSnapshot{&event.After}, &event.ClientIP, &event.AdminOverride)
+		if err != nil {
+			return events, fmt.Errorf("failed while scanning: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// AddRevision implements Revisions. The next number is chosen in the same
+// statement as the insert, which SQLite runs one at a time.
+func (s *sqlite) AddRevision(ctx context.Context, revision LinkRevision) (LinkRevision, error) {
+	row := s.db.QueryRowContext(ctx,
+		`insert into link_revisions(`+revisionColumns+`)
+		select $1, coalesce(max(number), 0) + 1, $2, $3, $4, $5, $6 from link_revisions where link = $1
+		returning number`,
+		revision.Link, revision.URL, revision.Description, revision.Author, revision.Created.UTC(), revision.RolledBackFrom,
+	)
+	err := row.Scan(&revision.Number)
+	return revision, err
+}
+
+// GetRevisions implements Revisions.
+func (s *sqlite) GetRevisions(ctx context.Context, name string) ([]LinkRevision, error) {
+	revisions := []LinkRevision{}
+	rows, err := s.db.QueryContext(ctx, `select `+revisionColumns+` from link_revisions where link = $1 order by number`, name)
+	if err != nil {
+		return revisions, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var revision LinkRevision
+		err = rows.Scan(&revision.Link, &revision.Number, &revision.URL, &revision.Description, &revision.Author, &revision.Created, &revision.RolledBackFrom)
+		if err != nil {
+			return revisions, fmt.Errorf("failed while scanning: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+const sqliteLinkColumns = `links.name, links.description, links.url, links.views, links.created_at, links.updated_at, links.created_by, links.disabled, links.disabled_at, links.owners`
+
+func (s *sqlite) getSingleResult(ctx context.Context, query string, args ...any) (Link, error) {
+	row := s.db.QueryRowContext(ctx, query, args...)
+	link := Link{}
+	err := row.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled, &link.DisabledAt, (*sqliteOwners)(&link.Owners))
+	if errors.Is(err, sql.ErrNoRows) {
+		return link, ErrLinkNotFound
+	}
+	if err != nil {
+		return link, err
+	}
+	return link, nil
+}
+
+func (s *sqlite) getMultipleResults(ctx context.Context, query string, args ...any) ([]Link, error) {
+	links := []Link{}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return links, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link Link
+		err = rows.Scan(&link.Name, &link.Description, &link.URL, &link.Views, &link.Created, &link.Updated, &link.CreatedBy, &link.Disabled, &link.DisabledAt, (*sqliteOwners)(&link.Owners))
+		if err != nil {
+			return links, fmt.Errorf("failed while scanning: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (s *sqlite) execSingle(ctx context.Context, query string, args ...any) error {
+	resp, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	count, err := resp.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+func (s *sqlite) ensureTables(ctx context.Context) error {
+	statements := []string{
+		`create table if not exists links (
+			name text not null primary key collate nocase,
+			description text not null default '',
+			url text not null,
+			views integer not null default 0,
+			created_at timestamp not null,
+			updated_at timestamp not null,
+			created_by text not null default '',
+			disabled boolean not null default false,
+			disabled_at timestamp,
+			owners text not null default '[]'
+		)`,
+		`create index if not exists links_views on links (views)`,
+		`create index if not exists links_updated_at on links (updated_at)`,
+		`drop index if exists links_created_by`,
+		`create virtual table if not exists links_fts using fts5(
+			name, description, content='links', content_rowid='rowid', tokenize='trigram'
+		)`,
+		`create trigger if not exists links_fts_insert after insert on links begin
+			insert into links_fts(rowid, name, description) values (new.rowid, new.name, new.description);
+		end`,
+		`create trigger if not exists links_fts_delete after delete on links begin
+			insert into links_fts(links_fts, rowid, name, description) values ('delete', old.rowid, old.name, old.description);
+		end`,
+		`create trigger if not exists links_fts_update after update of name, description on links begin
+			insert into links_fts(links_fts, rowid, name, description) values ('delete', old.rowid, old.name, old.description);
+			insert into links_fts(rowid, name, description) values (new.rowid, new.name, new.description);
+		end`,
+		`create table if not exists clicks (
+			link text not null,
+			clicked_at timestamp not null,
+			referrer text,
+			username text
+		)`,
+		`create index if not exists clicks_link_clicked_at on clicks (link, clicked_at)`,
+		`create index if not exists clicks_clicked_at on clicks (clicked_at)`,
+		`create table if not exists api_tokens (
+			id text not null primary key,
+			hash text not null unique,
+			owner text not null,
+			name text not null default '',
+			scope text not null,
+			created_at timestamp not null,
+			expires_at timestamp
+		)`,
+		`create index if not exists api_tokens_owner on api_tokens (owner collate nocase)`,
+		`create table if not exists audit_events (
+			id text not null primary key,
+			occurred_at timestamp not null,
+			actor text not null,
+			action text not null,
+			link text not null,
+			link_before text,
+			link_after text,
+			client_ip text not null default '',
+			admin_override boolean not null default false
+		)`,
+		`create index if not exists audit_events_link_occurred_at on audit_events (link collate nocase, occurred_at)`,
+		`create index if not exists audit_events_actor_occurred_at on audit_events (actor collate nocase, occurred_at)`,
+		`create index if not exists audit_events_occurred_at on audit_events (occurred_at)`,
+		`create table if not exists link_revisions (
+			link text not null collate nocase,
+			number integer not null,
+			url text not null,
+			description text not null default '',
+			author text not null default '',
+			created_at timestamp not null,
+			rolled_back_from integer not null default 0,
+			primary key (link, number)
+		)`,
+	}
+	for _, statement := range statements {
+		_, err := s.db.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	return s.migrateOwners(ctx)
+}
+
+// migrateOwners adds the owners column to databases created before links
+// could have several owners, making each link's creator its owner.
+func (s *sqlite) migrateOwners(ctx context.Context) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `select count(*) > 0 from pragma_table_info('links') where name = 'owners'`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		_, err = s.db.ExecContext(ctx, `alter table links add column owners text not null default '[]'`)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = s.db.ExecContext(ctx, `update links set owners = json_array(created_by) where owners = '[]' and created_by <> ''`)
+	if err != nil {
+		return err
+	}
+	return s.ensureLinkOwners(ctx)
+}
+
+// ensureLinkOwners keeps a row per owner of each link in link_owners, updated
+// by triggers, so links can be looked up by owner with an index. The table is
+// filled from links when it is first created.
+func (s *sqlite) ensureLinkOwners(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var exists bool
+	err = tx.QueryRowContext(ctx, `select count(*) > 0 from sqlite_master where type = 'table' and name = 'link_owners'`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`create table if not exists link_owners (
+			name text not null collate nocase,
+			owner text not null collate nocase
+		)`,
+		`create index if not exists link_owners_owner on link_owners (owner)`,
+		`create index if not exists link_owners_name on link_owners (name)`,
+		`create trigger if not exists link_owners_insert after insert on links begin
+			insert into link_owners(name, owner) select new.name, value from json_each(new.owners);
+		end`,
+		`create trigger if not exists link_owners_delete after delete on links begin
+			delete from link_owners where name = old.name;
+		end`,
+		`create trigger if not exists link_owners_update after update of name, owners on links begin
+			delete from link_owners where name = old.name;
+			insert into link_owners(name, owner) select new.name, value from json_each(new.owners);
+		end`,
+	}
+	if !exists {
+		statements = append(statements, `insert into link_owners(name, owner) select links.name, json_each.value from links, json_each(links.owners)`)
+	}
+	for _, statement := range statements {
+		_, err := tx.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
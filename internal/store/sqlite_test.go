@@ -0,0 +1,143 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := store.NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "links.db"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close(ctx)
+
+	links := []store.Link{
+		{Name: "docs", Description: "Team documentation", URL: "https://example.com/docs", CreatedBy: "user@example.com"},
+		{Name: "oncall", Description: "Who is on call this week", URL: "https://example.com/oncall", CreatedBy: "other@example.com"},
+		{Name: "docs/api", Description: "API reference", URL: "https://example.com/api", CreatedBy: "user@example.com"},
+	}
+	for _, link := range links {
+		if !assert.NoError(t, s.CreateLink(ctx, link)) {
+			t.FailNow()
+		}
+	}
+	assert.ErrorIs(t, s.CreateLink(ctx, store.Link{Name: "DOCS", URL: "https://example.com"}), store.ErrIDExists)
+
+	link, err := s.GetLinkByName(ctx, "Docs")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "https://example.com/docs", link.URL)
+	assert.WithinDuration(t, time.Now(), link.Created, time.Minute)
+	assert.Nil(t, link.DisabledAt)
+
+	for range [3]int{} {
+		s.IncrementLinkViews(ctx, "oncall")
+	}
+	popular, _ := s.GetPopularLinks(ctx, 1)
+	if assert.Equal(t, 1, len(popular)) {
+		assert.Equal(t, "oncall", popular[0].Name)
+		assert.Equal(t, 3, popular[0].Views)
+	}
+
+	found, err := s.QueryLinks(ctx, "documentation")
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(found)) {
+		assert.Equal(t, "docs", found[0].Name)
+	}
+	found, _ = s.QueryLinks(ctx, "ref")
+	assert.Equal(t, 1, len(found))
+	found, _ = s.QueryLinks(ctx, "do")
+	assert.Equal(t, 2, len(found))
+
+	url := "https://example.com/oncall/schedule"
+	description := "On call schedule"
+	assert.NoError(t, s.UpdateLink(ctx, "oncall", store.LinkPatch{URL: &url, Description: &description}))
+	found, _ = s.QueryLinks(ctx, "schedule")
+	assert.Equal(t, 1, len(found))
+	found, _ = s.QueryLinks(ctx, "this week")
+	assert.Equal(t, 0, len(found))
+
+	assert.NoError(t, s.DisableLink(ctx, "docs"))
+	_, err = s.GetLinkByName(ctx, "docs")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	trash, _ := s.GetDisabledLinks(ctx, []string{"user@example.com"})
+	if assert.Equal(t, 1, len(trash)) {
+		assert.NotNil(t, trash[0].DisabledAt)
+	}
+	count, err := s.PurgeDisabledLinks(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	wednesday := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	s.RecordClick(ctx, store.Click{Link: "oncall", Timestamp: wednesday, User: "a@example.com"})
+	s.RecordClick(ctx, store.Click{Link: "oncall", Timestamp: wednesday.AddDate(0, 0, 1)})
+	s.RecordClick(ctx, store.Click{Link: "oncall", Timestamp: wednesday.AddDate(0, 0, 7)})
+	weekly, err := s.GetClickStats(ctx, "oncall", store.StatsIntervalWeek, wednesday.AddDate(0, 0, -7))
+	assert.NoError(t, err)
+	assert.Equal(t, []store.ClickBucket{
+		{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Clicks: 2, UniqueUsers: 1},
+		{Start: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Clicks: 1, UniqueUsers: 0},
+	}, weekly)
+
+	assert.NoError(t, s.RecordClicks(ctx, []store.Click{
+		{Link: "oncall", Timestamp: wednesday.AddDate(0, 0, 8), Referrer: "example.com"},
+		{Link: "oncall", Timestamp: wednesday.AddDate(0, 0, 8), User: "b@example.com"},
+	}))
+	count, err = s.PurgeClicks(ctx, wednesday.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	weekly, err = s.GetClickStats(ctx, "oncall", store.StatsIntervalWeek, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []store.ClickBucket{
+		{Start: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Clicks: 3, UniqueUsers: 1},
+	}, weekly)
+}
+
+func TestSQLiteStoreMigratesOwners(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.db")
+	db, err := sql.Open("sqlite", path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the links table as it was before links had owners
+	_, err = db.ExecContext(ctx, `create table links (
+		name text not null primary key collate nocase,
+		description text not null default '',
+		url text not null,
+		views integer not null default 0,
+		created_at timestamp not null,
+		updated_at timestamp not null,
+		created_by text not null default '',
+		disabled boolean not null default false,
+		disabled_at timestamp
+	)`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = db.ExecContext(ctx, `insert into links(name, url, created_at, updated_at, created_by) values ('docs', 'https://example.com/docs', $1, $1, 'user@example.com')`, time.Now().UTC())
+	assert.NoError(t, err)
+	db.Close()
+
+	s, err := store.NewSQLiteStore(ctx, path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close(ctx)
+	owned, err := s.GetOwnedLinks(ctx, []string{"user@example.com"})
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(owned)) {
+		assert.Equal(t, []string{"user@example.com"}, owned[0].Owners)
+	}
+	// existing links are indexed by owner when link_owners is created
+	owned, err = s.GetOwnedLinks(ctx, []string{"USER@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(owned))
+}
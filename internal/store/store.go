@@ -4,18 +4,78 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/imdevinc/go-links/internal/config"
 )
 
 type Link struct {
-	Name        string    `json:"name" bson:"_id"`
-	Description string    `json:"description" bson:"description"`
-	URL         string    `json:"url" bson:"url"`
-	Views       int       `json:"views" bson:"views"`
-	Created     time.Time `json:"created_at" bson:"created_at"`
-	Updated     time.Time `json:"updated_at" bson:"updated_at"`
-	CreatedBy   string    `json:"created_by" bson:"created_by"`
-	Disabled    bool      `json:"disabled" bson:"disabled"`
+	Name        string     `json:"name" bson:"_id"`
+	Description string     `json:"description" bson:"description"`
+	URL         string     `json:"url" bson:"url"`
+	Views       int        `json:"views" bson:"views"`
+	Created     time.Time  `json:"created_at" bson:"created_at"`
+	Updated     time.Time  `json:"updated_at" bson:"updated_at"`
+	CreatedBy   string     `json:"created_by" bson:"created_by"`
+	Disabled    bool       `json:"disabled" bson:"disabled"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty" bson:"disabled_at,omitempty"`
+	// Owners are the users and groups (prefixed with GroupOwnerPrefix) that can
+	// manage the link. New links are owned by CreatedBy.
+	Owners []string `json:"owners" bson:"owners"`
+}
+
+// GroupOwnerPrefix marks an entry of Link.Owners as a group instead of a user.
+const GroupOwnerPrefix = "group:"
+
+// OwnerPrincipals returns the Link.Owners entries that match a user with the
+// given email and groups.
+func OwnerPrincipals(email string, groups []string) []string {
+	principals := []string{email}
+	for _, group := range groups {
+		principals = append(principals, GroupOwnerPrefix+group)
+	}
+	return principals
+}
+
+// OwnedBy reports whether any of principals is one of the link's owners.
+func (l Link) OwnedBy(principals []string) bool {
+	for _, owner := range l.Owners {
+		for _, principal := range principals {
+			if strings.EqualFold(owner, principal) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withDefaultOwners makes CreatedBy the owner of links without owners.
+func (l Link) withDefaultOwners() Link {
+	if len(l.Owners) == 0 && l.CreatedBy != "" {
+		l.Owners = []string{l.CreatedBy}
+	}
+	return l
+}
+
+// nonNilOwners returns owners, or an empty list if it is nil, for the stores
+// that can not store a nil list.
+func nonNilOwners(owners []string) []string {
+	if owners == nil {
+		return []string{}
+	}
+	return owners
+}
+
+// LinkPatch describes the editable fields of a Link. Nil fields are left unchanged.
+type LinkPatch struct {
+	Description *string `json:"description,omitempty"`
+	URL         *string `json:"url,omitempty"`
+	// Owners replaces the link's owners. It is only changed through the
+	// ownership endpoints, never from a client's payload.
+	Owners []string `json:"-"`
 }
 
 func CreateLinkFromPayload(payload []byte) (Link, error) {
@@ -27,18 +87,106 @@ func CreateLinkFromPayload(payload []byte) (Link, error) {
 	return link, nil
 }
 
+func CreateLinkPatchFromPayload(payload []byte) (LinkPatch, error) {
+	var patch LinkPatch
+	err := json.Unmarshal(payload, &patch)
+	if err != nil {
+		return LinkPatch{}, err
+	}
+	return patch, nil
+}
+
+// apply copies the set fields of the patch onto link and bumps its Updated time.
+func (p LinkPatch) apply(link Link) Link {
+	if p.Description != nil {
+		link.Description = *p.Description
+	}
+	if p.URL != nil {
+		link.URL = *p.URL
+	}
+	if p.Owners != nil {
+		link.Owners = p.Owners
+	}
+	link.Updated = time.Now()
+	return link
+}
+
 var ErrIDExists = errors.New("id exists")
 var ErrLinkNotFound = errors.New("link not found")
+var ErrStoreNotFound = errors.New("store does not exist")
 
 type Store interface {
 	CreateLink(ctx context.Context, link Link) error
 	GetLinkByName(ctx context.Context, name string) (Link, error)
 	GetLinkByURL(ctx context.Context, url string) (Link, error)
+	UpdateLink(ctx context.Context, name string, patch LinkPatch) error
 	DisableLink(ctx context.Context, name string) error
+	RestoreLink(ctx context.Context, name string) error
+	PurgeLink(ctx context.Context, name string) error
+	PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error)
+	// GetDisabledLinks returns the disabled links owned by any of owners.
+	GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error)
 	GetPopularLinks(ctx context.Context, size int) ([]Link, error)
 	GetRecentLinks(ctx context.Context, size int) ([]Link, error)
-	GetOwnedLinks(ctx context.Context, email string) ([]Link, error)
+	// GetOwnedLinks returns the links owned by any of owners, see OwnerPrincipals.
+	GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error)
 	IncrementLinkViews(ctx context.Context, name string) error
+	AddLinkViews(ctx context.Context, name string, delta int) error
 	QueryLinks(ctx context.Context, query string) ([]Link, error)
+	// WalkLinks calls fn for every link, including disabled ones, stopping at the first error.
+	WalkLinks(ctx context.Context, fn func(Link) error) error
+	// ImportLink stores link exactly as given, keeping its views and timestamps.
+	// Existing links are replaced when overwrite is set, otherwise ErrIDExists is returned.
+	ImportLink(ctx context.Context, link Link, overwrite bool) error
+	// Ping checks that the store can serve requests, such as that its
+	// database is reachable.
+	Ping(ctx context.Context) error
 	Close(ctx context.Context) error
 }
+
+// Options changes how Open opens a store.
+type Options struct {
+	// MustExist fails with ErrStoreNotFound instead of creating a store that
+	// does not exist yet.
+	MustExist bool
+	// ReadOnly opens an existing store without creating or migrating its
+	// tables, indexes or files, so opening it writes nothing. It implies
+	// MustExist, and changes must not be made to the store.
+	ReadOnly bool
+}
+
+// New creates the store selected by cfg.
+func New(ctx context.Context, cfg config.StoreConfig) (Store, error) {
+	return Open(ctx, cfg, Options{})
+}
+
+// Open opens the store selected by cfg with opts.
+func Open(ctx context.Context, cfg config.StoreConfig, opts Options) (Store, error) {
+	var s Store
+	var err error
+	switch cfg.StoreType {
+	case config.StoreTypeFile:
+		s, err = NewFileStore(cfg.File.Path, !opts.MustExist && !opts.ReadOnly, FileOptions{
+			Journal:         cfg.File.Journal,
+			CompactInterval: cfg.File.CompactInterval,
+			ReadOnly:        opts.ReadOnly,
+		})
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("%w: %s", ErrStoreNotFound, cfg.File.Path)
+		}
+	case config.StoreTypeMemory:
+		s = NewMemoryStore()
+	case config.StoreTypeMongo:
+		s, err = openMongoDB(ctx, cfg.Mongo.Username, cfg.Mongo.Password, cfg.Mongo.Host, cfg.Mongo.DatabaseName, opts)
+	case config.StoreTypePostgres:
+		s, err = openPostgres(ctx, cfg.Postgres.Username, cfg.Postgres.Password, cfg.Postgres.Host, cfg.Postgres.DatabaseName, opts)
+	case config.StoreTypeSQLite:
+		s, err = openSQLite(ctx, cfg.SQLite.Path, opts)
+	default:
+		return nil, fmt.Errorf("unknown store type %q", cfg.StoreType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,672 @@
+// Package storetest provides a behavioral test suite that every store.Store
+// implementation is expected to pass.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var tests = []struct {
+	name string
+	fn   func(t *testing.T, s store.Store)
+}{
+	{name: "Ping", fn: testPing},
+	{name: "CreateLink", fn: testCreateLink},
+	{name: "DuplicateLink", fn: testDuplicateLink},
+	{name: "CaseInsensitiveLookup", fn: testCaseInsensitiveLookup},
+	{name: "ConcurrentCaseVariants", fn: testConcurrentCaseVariants},
+	{name: "ImportOverwrite", fn: testImportOverwrite},
+	{name: "UpdateLink", fn: testUpdateLink},
+	{name: "DisabledFiltering", fn: testDisabledFiltering},
+	{name: "OwnedFiltering", fn: testOwnedFiltering},
+	{name: "PopularLinks", fn: testPopularLinks},
+	{name: "RecentLinks", fn: testRecentLinks},
+	{name: "QueryLinks", fn: testQueryLinks},
+	{name: "ConcurrentIncrements", fn: testConcurrentIncrements},
+	{name: "WalkLinks", fn: testWalkLinks},
+	{name: "Tokens", fn: testTokens},
+	{name: "Audit", fn: testAudit},
+	{name: "Revisions", fn: testRevisions},
+}
+
+// Run runs every test in the suite against a store returned by newStore, which
+// is called once per test and must return a store without any links. Closing
+// the store is left to newStore, typically with t.Cleanup.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// Clear removes every link from s, including disabled links, so a store that
+// cannot be recreated between tests can be reused.
+func Clear(ctx context.Context, s store.Store) error {
+	links := []store.Link{}
+	err := s.WalkLinks(ctx, func(link store.Link) error {
+		links = append(links, link)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if !link.Disabled {
+			err = s.DisableLink(ctx, link.Name)
+			if err != nil && !errors.Is(err, store.ErrLinkNotFound) {
+				return err
+			}
+		}
+		err = s.PurgeLink(ctx, link.Name)
+		if err != nil && !errors.Is(err, store.ErrLinkNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func createLinks(t *testing.T, s store.Store, links ...store.Link) {
+	t.Helper()
+	for _, link := range links {
+		if !assert.NoError(t, s.CreateLink(context.Background(), link)) {
+			t.FailNow()
+		}
+	}
+}
+
+func names(links []store.Link) []string {
+	result := []string{}
+	for _, link := range links {
+		result = append(result, link.Name)
+	}
+	return result
+}
+
+func sortedNames(links []store.Link) []string {
+	result := names(links)
+	slices.Sort(result)
+	return result
+}
+
+func testPing(t *testing.T, s store.Store) {
+	assert.NoError(t, s.Ping(context.Background()))
+}
+
+func testCreateLink(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s, store.Link{
+		Name:        "docs",
+		Description: "Team documentation",
+		URL:         "https://example.com/docs",
+		CreatedBy:   "user@example.com",
+	})
+
+	link, err := s.GetLinkByName(ctx, "docs")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "docs", link.Name)
+	assert.Equal(t, "Team documentation", link.Description)
+	assert.Equal(t, "https://example.com/docs", link.URL)
+	assert.Equal(t, "user@example.com", link.CreatedBy)
+	assert.Equal(t, 0, link.Views)
+	assert.False(t, link.Disabled)
+	assert.Nil(t, link.DisabledAt)
+	assert.WithinDuration(t, time.Now(), link.Created, time.Minute)
+	assert.True(t, link.Created.Equal(link.Updated), "updated %s should equal created %s", link.Updated, link.Created)
+
+	byURL, err := s.GetLinkByURL(ctx, "https://example.com/docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "docs", byURL.Name)
+	}
+	_, err = s.GetLinkByName(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+}
+
+func testDuplicateLink(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s, store.Link{Name: "docs", URL: "https://example.com/docs"})
+	assert.ErrorIs(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/other"}), store.ErrIDExists)
+	assert.ErrorIs(t, s.CreateLink(ctx, store.Link{Name: "DOCS", URL: "https://example.com/other"}), store.ErrIDExists)
+
+	// disabled links keep their name until they are purged
+	assert.NoError(t, s.DisableLink(ctx, "docs"))
+	assert.ErrorIs(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/other"}), store.ErrIDExists)
+	assert.NoError(t, s.PurgeLink(ctx, "docs"))
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com/other"}))
+
+	link, err := s.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/other", link.URL)
+	}
+}
+
+func testCaseInsensitiveLookup(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s, store.Link{Name: "Docs", URL: "https://example.com/docs"})
+
+	for _, name := range []string{"Docs", "docs", "DOCS"} {
+		link, err := s.GetLinkByName(ctx, name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, "Docs", link.Name)
+		}
+	}
+	assert.NoError(t, s.IncrementLinkViews(ctx, "dOCS"))
+	link, err := s.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, link.Views)
+	}
+	assert.NoError(t, s.DisableLink(ctx, "DOCS"))
+	_, err = s.GetLinkByName(ctx, "Docs")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+}
+
+func testConcurrentCaseVariants(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	names := []string{"docs", "Docs", "DOCS", "dOCS", "DoCs", "dOcS"}
+	created := make(chan string, len(names))
+	wg := sync.WaitGroup{}
+	for _, name := range names {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.CreateLink(ctx, store.Link{Name: name, URL: "https://example.com/" + name})
+			if err == nil {
+				created <- name
+			} else {
+				assert.ErrorIs(t, err, store.ErrIDExists, name)
+			}
+		}()
+	}
+	wg.Wait()
+	close(created)
+
+	winners := []string{}
+	for name := range created {
+		winners = append(winners, name)
+	}
+	if assert.Len(t, winners, 1, "only one case variant is created") {
+		link, err := s.GetLinkByName(ctx, "docs")
+		if assert.NoError(t, err) {
+			assert.Equal(t, winners[0], link.Name)
+		}
+	}
+}
+
+func testImportOverwrite(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s, store.Link{Name: "docs", URL: "https://example.com/docs"})
+
+	imported := store.Link{Name: "Docs", URL: "https://example.com/imported", Views: 3}
+	assert.ErrorIs(t, s.ImportLink(ctx, imported, false), store.ErrIDExists)
+	assert.NoError(t, s.ImportLink(ctx, imported, true))
+
+	walked := []store.Link{}
+	err := s.WalkLinks(ctx, func(link store.Link) error {
+		walked = append(walked, link)
+		return nil
+	})
+	if assert.NoError(t, err) && assert.Len(t, walked, 1, "the link is replaced, not duplicated") {
+		assert.Equal(t, "Docs", walked[0].Name)
+		assert.Equal(t, "https://example.com/imported", walked[0].URL)
+		assert.Equal(t, 3, walked[0].Views)
+	}
+}
+
+func testUpdateLink(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s, store.Link{Name: "docs", Description: "Team documentation", URL: "https://example.com/docs", CreatedBy: "user@example.com"})
+	assert.NoError(t, s.AddLinkViews(ctx, "docs", 3))
+	before, err := s.GetLinkByName(ctx, "docs")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	url := "https://example.com/wiki"
+	assert.NoError(t, s.UpdateLink(ctx, "docs", store.LinkPatch{URL: &url}))
+	after, err := s.GetLinkByName(ctx, "docs")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, url, after.URL)
+	assert.Equal(t, "Team documentation", after.Description)
+	assert.Equal(t, 3, after.Views)
+	assert.Equal(t, "user@example.com", after.CreatedBy)
+	assert.True(t, before.Created.Equal(after.Created), "created changed from %s to %s", before.Created, after.Created)
+	assert.True(t, after.Updated.After(before.Updated), "updated %s should be after %s", after.Updated, before.Updated)
+
+	assert.ErrorIs(t, s.UpdateLink(ctx, "missing", store.LinkPatch{URL: &url}), store.ErrLinkNotFound)
+}
+
+func testDisabledFiltering(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s,
+		store.Link{Name: "docs", Description: "Team documentation", URL: "https://example.com/docs", CreatedBy: "user@example.com"},
+		store.Link{Name: "wiki", Description: "Team wiki", URL: "https://example.com/wiki", CreatedBy: "user@example.com"},
+	)
+	assert.NoError(t, s.DisableLink(ctx, "wiki"))
+	assert.ErrorIs(t, s.DisableLink(ctx, "wiki"), store.ErrLinkNotFound)
+
+	_, err := s.GetLinkByName(ctx, "wiki")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	_, err = s.GetLinkByURL(ctx, "https://example.com/wiki")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	assert.ErrorIs(t, s.IncrementLinkViews(ctx, "wiki"), store.ErrLinkNotFound)
+	description := "Old wiki"
+	assert.ErrorIs(t, s.UpdateLink(ctx, "wiki", store.LinkPatch{Description: &description}), store.ErrLinkNotFound)
+
+	popular, err := s.GetPopularLinks(ctx, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, names(popular))
+	}
+	recent, err := s.GetRecentLinks(ctx, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, names(recent))
+	}
+	owned, err := s.GetOwnedLinks(ctx, []string{"user@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, names(owned))
+	}
+	found, err := s.QueryLinks(ctx, "team")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, names(found))
+	}
+	disabled, err := s.GetDisabledLinks(ctx, []string{"user@example.com"})
+	if assert.NoError(t, err) && assert.Equal(t, []string{"wiki"}, names(disabled)) {
+		assert.True(t, disabled[0].Disabled)
+		if assert.NotNil(t, disabled[0].DisabledAt) {
+			assert.WithinDuration(t, time.Now(), *disabled[0].DisabledAt, time.Minute)
+		}
+	}
+
+	assert.ErrorIs(t, s.RestoreLink(ctx, "docs"), store.ErrLinkNotFound)
+	assert.NoError(t, s.RestoreLink(ctx, "wiki"))
+	link, err := s.GetLinkByName(ctx, "wiki")
+	if assert.NoError(t, err) {
+		assert.False(t, link.Disabled)
+		assert.Nil(t, link.DisabledAt)
+	}
+	assert.ErrorIs(t, s.PurgeLink(ctx, "wiki"), store.ErrLinkNotFound)
+}
+
+func testOwnedFiltering(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s,
+		store.Link{Name: "docs", URL: "https://example.com/docs", CreatedBy: "user@example.com"},
+		store.Link{Name: "wiki", URL: "https://example.com/wiki", CreatedBy: "user@example.com"},
+		store.Link{Name: "oncall", URL: "https://example.com/oncall", CreatedBy: "other@example.com"},
+	)
+
+	owned, err := s.GetOwnedLinks(ctx, []string{"user@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs", "wiki"}, sortedNames(owned))
+	}
+	owned, err = s.GetOwnedLinks(ctx, []string{"Other@Example.com"})
+	if assert.NoError(t, err) && assert.Equal(t, []string{"oncall"}, sortedNames(owned)) {
+		assert.Equal(t, []string{"other@example.com"}, owned[0].Owners)
+	}
+	owned, err = s.GetOwnedLinks(ctx, []string{"nobody@example.com"})
+	if assert.NoError(t, err) {
+		assert.Empty(t, owned)
+	}
+
+	// co-owners and groups
+	assert.NoError(t, s.UpdateLink(ctx, "docs", store.LinkPatch{Owners: []string{"user@example.com", "co-owner@example.com", store.GroupOwnerPrefix + "Engineering"}}))
+	owned, err = s.GetOwnedLinks(ctx, []string{"CO-OWNER@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, sortedNames(owned))
+	}
+	owned, err = s.GetOwnedLinks(ctx, store.OwnerPrincipals("nobody@example.com", []string{"sales", "engineering"}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, sortedNames(owned))
+	}
+	description := "Docs"
+	assert.NoError(t, s.UpdateLink(ctx, "docs", store.LinkPatch{Description: &description}))
+	link, err := s.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Len(t, link.Owners, 3)
+	}
+
+	// transferring replaces the owners, leaving CreatedBy as it was
+	assert.NoError(t, s.UpdateLink(ctx, "wiki", store.LinkPatch{Owners: []string{"other@example.com"}}))
+	owned, err = s.GetOwnedLinks(ctx, []string{"other@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"oncall", "wiki"}, sortedNames(owned))
+	}
+	owned, err = s.GetOwnedLinks(ctx, []string{"user@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs"}, sortedNames(owned))
+	}
+	link, err = s.GetLinkByName(ctx, "wiki")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "user@example.com", link.CreatedBy)
+	}
+	assert.NoError(t, s.DisableLink(ctx, "wiki"))
+	disabled, err := s.GetDisabledLinks(ctx, []string{"Other@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"wiki"}, names(disabled))
+	}
+
+	// imported links without owners are owned by their creator
+	assert.NoError(t, s.ImportLink(ctx, store.Link{Name: "legacy", URL: "https://example.com/legacy", CreatedBy: "user@example.com"}, false))
+	owned, err = s.GetOwnedLinks(ctx, []string{"user@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs", "legacy"}, sortedNames(owned))
+	}
+}
+
+func testPopularLinks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s,
+		store.Link{Name: "docs", URL: "https://example.com/docs"},
+		store.Link{Name: "wiki", URL: "https://example.com/wiki"},
+		store.Link{Name: "oncall", URL: "https://example.com/oncall"},
+	)
+	assert.NoError(t, s.AddLinkViews(ctx, "docs", 1))
+	assert.NoError(t, s.AddLinkViews(ctx, "wiki", 5))
+	assert.NoError(t, s.AddLinkViews(ctx, "oncall", 3))
+
+	popular, err := s.GetPopularLinks(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"wiki", "oncall"}, names(popular))
+	}
+	popular, err = s.GetPopularLinks(ctx, 10)
+	if assert.NoError(t, err) && assert.Equal(t, []string{"wiki", "oncall", "docs"}, names(popular)) {
+		assert.Equal(t, 5, popular[0].Views)
+	}
+}
+
+func testRecentLinks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	for _, name := range []string{"docs", "wiki", "oncall"} {
+		createLinks(t, s, store.Link{Name: name, URL: "https://example.com/" + name})
+		time.Sleep(10 * time.Millisecond)
+	}
+	description := "Team documentation"
+	assert.NoError(t, s.UpdateLink(ctx, "docs", store.LinkPatch{Description: &description}))
+
+	recent, err := s.GetRecentLinks(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs", "oncall"}, names(recent))
+	}
+	recent, err = s.GetRecentLinks(ctx, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs", "oncall", "wiki"}, names(recent))
+	}
+}
+
+func testQueryLinks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s,
+		store.Link{Name: "docs", Description: "Team documentation", URL: "https://example.com/docs"},
+		store.Link{Name: "oncall", Description: "Who is on call this week", URL: "https://example.com/oncall"},
+		store.Link{Name: "handbook", Description: "Company documentation", URL: "https://example.com/handbook"},
+	)
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "documentation", expected: []string{"docs", "handbook"}},
+		{query: "Documentation", expected: []string{"docs", "handbook"}},
+		{query: "oncall", expected: []string{"oncall"}},
+		{query: "HANDBOOK", expected: []string{"handbook"}},
+		{query: "missing", expected: []string{}},
+	}
+	for _, tc := range cases {
+		found, err := s.QueryLinks(ctx, tc.query)
+		if assert.NoError(t, err, tc.query) {
+			assert.Equal(t, tc.expected, sortedNames(found), tc.query)
+		}
+	}
+}
+
+func testConcurrentIncrements(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s, store.Link{Name: "docs", URL: "https://example.com/docs"})
+
+	const increments = 50
+	wg := sync.WaitGroup{}
+	for i := 0; i < increments; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.IncrementLinkViews(ctx, "docs"))
+		}()
+	}
+	wg.Wait()
+
+	link, err := s.GetLinkByName(ctx, "docs")
+	if assert.NoError(t, err) {
+		assert.Equal(t, increments, link.Views)
+	}
+}
+
+func testWalkLinks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createLinks(t, s,
+		store.Link{Name: "docs", URL: "https://example.com/docs"},
+		store.Link{Name: "wiki", URL: "https://example.com/wiki"},
+	)
+	assert.NoError(t, s.DisableLink(ctx, "wiki"))
+
+	walked := []store.Link{}
+	err := s.WalkLinks(ctx, func(link store.Link) error {
+		walked = append(walked, link)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"docs", "wiki"}, sortedNames(walked))
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.WalkLinks(ctx, func(link store.Link) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+// testTokens is skipped for stores that do not implement store.Tokens. Tokens
+// are not removed by Clear, so every run uses its own owner and IDs.
+func testTokens(t *testing.T, s store.Store) {
+	tokens, ok := s.(store.Tokens)
+	if !ok {
+		t.Skip("store does not implement store.Tokens")
+	}
+	ctx := context.Background()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	owner := "tokens-" + run + "@example.com"
+	created := time.Now().UTC().Truncate(time.Second)
+	expires := created.Add(time.Hour)
+	older := store.APIToken{ID: run + "-1", Hash: run + "-hash-1", Owner: owner, Name: "ci", Scope: store.TokenScopeRead, Created: created.Add(-time.Minute)}
+	newer := store.APIToken{ID: run + "-2", Hash: run + "-hash-2", Owner: owner, Name: "cli", Scope: store.TokenScopeWrite, Created: created, Expires: &expires}
+	other := store.APIToken{ID: run + "-3", Hash: run + "-hash-3", Owner: "other-" + owner, Scope: store.TokenScopeRead, Created: created}
+	for _, token := range []store.APIToken{older, newer, other} {
+		if !assert.NoError(t, tokens.CreateToken(ctx, token)) {
+			t.FailNow()
+		}
+	}
+	assert.ErrorIs(t, tokens.CreateToken(ctx, store.APIToken{ID: run + "-4", Hash: newer.Hash, Owner: owner, Created: created}), store.ErrIDExists)
+
+	found, err := tokens.GetTokenByHash(ctx, newer.Hash)
+	if assert.NoError(t, err) {
+		assert.Equal(t, newer.ID, found.ID)
+		assert.Equal(t, store.TokenScopeWrite, found.Scope)
+		if assert.NotNil(t, found.Expires) {
+			assert.True(t, expires.Equal(*found.Expires))
+		}
+	}
+	_, err = tokens.GetTokenByHash(ctx, run+"-missing")
+	assert.ErrorIs(t, err, store.ErrTokenNotFound)
+
+	owned, err := tokens.GetTokens(ctx, strings.ToUpper(owner))
+	if assert.NoError(t, err) && assert.Len(t, owned, 2) {
+		assert.Equal(t, newer.ID, owned[0].ID)
+		assert.Equal(t, older.ID, owned[1].ID)
+		assert.Nil(t, owned[1].Expires)
+	}
+
+	assert.ErrorIs(t, tokens.DeleteToken(ctx, owner, other.ID), store.ErrTokenNotFound)
+	assert.NoError(t, tokens.DeleteToken(ctx, owner, older.ID))
+	assert.ErrorIs(t, tokens.DeleteToken(ctx, owner, older.ID), store.ErrTokenNotFound)
+	_, err = tokens.GetTokenByHash(ctx, older.Hash)
+	assert.ErrorIs(t, err, store.ErrTokenNotFound)
+}
+
+func testAudit(t *testing.T, s store.Store) {
+	audit, ok := s.(store.Audit)
+	if !ok {
+		t.Skip("store does not implement store.Audit")
+	}
+	ctx := context.Background()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	actor := "audit-" + run + "@example.com"
+	link := "audit-" + run
+	start := time.Now().UTC().Truncate(time.Second)
+	before := store.Link{Name: link, URL: "https://example.com/old", CreatedBy: actor, Owners: []string{actor}}
+	after := before
+	after.URL = "https://example.com/new"
+	events := []store.AuditEvent{
+		{ID: run + "-1", Time: start, Actor: actor, Action: store.AuditActionCreate, Link: link, After: &before, ClientIP: "192.0.2.1"},
+		{ID: run + "-2", Time: start.Add(time.Minute), Actor: "other-" + actor, Action: store.AuditActionUpdate, Link: link, Before: &before, After: &after, AdminOverride: true},
+		{ID: run + "-3", Time: start.Add(2 * time.Minute), Actor: actor, Action: store.AuditActionCreate, Link: link + "-other"},
+	}
+	for _, event := range events {
+		if !assert.NoError(t, audit.RecordAuditEvent(ctx, event)) {
+			t.FailNow()
+		}
+	}
+	assert.ErrorIs(t, audit.RecordAuditEvent(ctx, store.AuditEvent{ID: events[0].ID, Time: start, Actor: actor, Link: link}), store.ErrIDExists)
+
+	ids := func(events []store.AuditEvent) []string {
+		ids := []string{}
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return ids
+	}
+	found, err := audit.GetAuditEvents(ctx, store.AuditFilter{Link: strings.ToUpper(link)})
+	if assert.NoError(t, err) && assert.Equal(t, []string{run + "-2", run + "-1"}, ids(found)) {
+		assert.Nil(t, found[1].Before)
+		assert.Equal(t, "192.0.2.1", found[1].ClientIP)
+		assert.True(t, found[0].AdminOverride)
+		if assert.NotNil(t, found[0].Before) && assert.NotNil(t, found[0].After) {
+			assert.Equal(t, "https://example.com/old", found[0].Before.URL)
+			assert.Equal(t, "https://example.com/new", found[0].After.URL)
+			assert.Equal(t, []string{actor}, found[0].After.Owners)
+		}
+		assert.True(t, start.Equal(found[1].Time))
+	}
+	found, _ = audit.GetAuditEvents(ctx, store.AuditFilter{Actor: strings.ToUpper(actor)})
+	assert.Equal(t, []string{run + "-3", run + "-1"}, ids(found))
+	found, _ = audit.GetAuditEvents(ctx, store.AuditFilter{Actor: actor, Limit: 1})
+	assert.Equal(t, []string{run + "-3"}, ids(found))
+	found, _ = audit.GetAuditEvents(ctx, store.AuditFilter{Link: link, Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)})
+	assert.Equal(t, []string{run + "-2"}, ids(found))
+}
+
+func testRevisions(t *testing.T, s store.Store) {
+	revisions, ok := s.(store.Revisions)
+	if !ok {
+		t.Skip("store does not implement store.Revisions")
+	}
+	ctx := context.Background()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	link := "history-" + run
+	created := time.Now().UTC().Truncate(time.Second)
+	for i, url := range []string{"https://example.com/1", "https://example.com/2", "https://example.com/1"} {
+		revision := store.LinkRevision{Link: link, Number: 42, URL: url, Author: "user@example.com", Created: created.Add(time.Duration(i) * time.Minute)}
+		if i == 2 {
+			revision.Link = strings.ToUpper(link)
+			revision.RolledBackFrom = 1
+		}
+		added, err := revisions.AddRevision(ctx, revision)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, i+1, added.Number)
+	}
+	_, err := revisions.AddRevision(ctx, store.LinkRevision{Link: link + "-other", URL: "https://example.com", Created: created})
+	assert.NoError(t, err)
+
+	found, err := revisions.GetRevisions(ctx, strings.ToUpper(link))
+	if assert.NoError(t, err) && assert.Len(t, found, 3) {
+		for i, revision := range found {
+			assert.Equal(t, i+1, revision.Number)
+		}
+		assert.Equal(t, "https://example.com/2", found[1].URL)
+		assert.Equal(t, "user@example.com", found[1].Author)
+		assert.True(t, created.Add(time.Minute).Equal(found[1].Created))
+		assert.Equal(t, 0, found[1].RolledBackFrom)
+		assert.Equal(t, 1, found[2].RolledBackFrom)
+	}
+	found, err = revisions.GetRevisions(ctx, link+"-missing")
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	// concurrent changes to a link still get distinct numbers
+	concurrent := link + "-concurrent"
+	var wg sync.WaitGroup
+	numbers := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			added, err := revisions.AddRevision(ctx, store.LinkRevision{Link: concurrent, URL: "https://example.com", Created: created})
+			if assert.NoError(t, err) {
+				numbers <- added.Number
+			}
+		}()
+	}
+	wg.Wait()
+	close(numbers)
+	seen := []int{}
+	for number := range numbers {
+		seen = append(seen, number)
+	}
+	slices.Sort(seen)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, seen)
+
+	// purging a link forgets its history, so a new link with the same name
+	// starts again from revision 1
+	createLinks(t, s,
+		store.Link{Name: strings.ToUpper(link), URL: "https://example.com/1"},
+		store.Link{Name: concurrent, URL: "https://example.com"},
+	)
+	for _, name := range []string{link, concurrent} {
+		if !assert.NoError(t, s.DisableLink(ctx, name)) {
+			t.FailNow()
+		}
+	}
+	assert.NoError(t, s.PurgeLink(ctx, link))
+	_, err = s.PurgeDisabledLinks(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	for _, name := range []string{link, concurrent} {
+		found, err = revisions.GetRevisions(ctx, name)
+		assert.NoError(t, err)
+		assert.Empty(t, found)
+		added, err := revisions.AddRevision(ctx, store.LinkRevision{Link: name, URL: "https://example.com/new", Created: created})
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, added.Number)
+		}
+	}
+	found, err = revisions.GetRevisions(ctx, link+"-other")
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+const tokenColumns = `id, hash, owner, name, scope, created_at, expires_at`
+
+type TokenScope string
+
+const (
+	// TokenScopeRead only allows reading links.
+	TokenScopeRead TokenScope = "read"
+	// TokenScopeWrite also allows creating, changing and deleting links.
+	TokenScopeWrite TokenScope = "write"
+)
+
+// APIToken is a personal access token. Only a hash of the secret is stored.
+type APIToken struct {
+	ID      string     `json:"id" bson:"_id"`
+	Hash    string     `json:"-" bson:"hash"`
+	Owner   string     `json:"owner" bson:"owner"`
+	Name    string     `json:"name" bson:"name"`
+	Scope   TokenScope `json:"scope" bson:"scope"`
+	Created time.Time  `json:"created_at" bson:"created_at"`
+	Expires *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+}
+
+// Expired reports whether the token can no longer be used at now.
+func (t APIToken) Expired(now time.Time) bool {
+	return t.Expires != nil && !now.Before(*t.Expires)
+}
+
+// Tokens stores personal access tokens.
+type Tokens interface {
+	CreateToken(ctx context.Context, token APIToken) error
+	// GetTokenByHash returns the token with the given secret hash, or ErrTokenNotFound.
+	GetTokenByHash(ctx context.Context, hash string) (APIToken, error)
+	// GetTokens returns the tokens owned by owner, newest first.
+	GetTokens(ctx context.Context, owner string) ([]APIToken, error)
+	// DeleteToken revokes the token with the given ID if it belongs to owner,
+	// otherwise ErrTokenNotFound is returned.
+	DeleteToken(ctx context.Context, owner string, id string) error
+}
+
+// tokenExists reports whether token's ID or hash is already used in tokens.
+func tokenExists(tokens map[string]APIToken, token APIToken) bool {
+	if _, ok := tokens[token.ID]; ok {
+		return true
+	}
+	for _, existing := range tokens {
+		if existing.Hash == token.Hash {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTokens returns the tokens owned by owner, newest first.
+func filterTokens(tokens []APIToken, owner string) []APIToken {
+	owned := []APIToken{}
+	for _, token := range tokens {
+		if strings.EqualFold(token.Owner, owner) {
+			owned = append(owned, token)
+		}
+	}
+	slices.SortFunc(owned, func(a APIToken, b APIToken) int {
+		return b.Created.Compare(a.Created)
+	})
+	return owned
+}
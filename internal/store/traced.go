@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by this package.
+const tracerName = "github.com/imdevinc/go-links/internal/store"
+
+// traced wraps a Store to record a span for every Store method, so slow
+// requests can be traced to the store call they spent their time in.
+// ErrLinkNotFound and ErrIDExists are expected answers rather than failures,
+// so they do not mark the span as failed.
+type traced struct {
+	Store
+	tracer trace.Tracer
+}
+
+var _ Store = (*traced)(nil)
+
+func NewTracedStore(s Store, provider trace.TracerProvider) *traced {
+	return &traced{
+		Store:  s,
+		tracer: provider.Tracer(tracerName),
+	}
+}
+
+func (t *traced) start(ctx context.Context, method string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "store."+method, opts...)
+}
+
+func (t *traced) end(span trace.Span, err error) {
+	recordSpanError(span, err)
+	span.End()
+}
+
+// recordSpanError marks span as failed unless err is nil or an expected answer.
+func recordSpanError(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrLinkNotFound) && !errors.Is(err, ErrIDExists) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// CreateLink implements Store.
+func (t *traced) CreateLink(ctx context.Context, link Link) error {
+	ctx, span := t.start(ctx, "CreateLink", trace.WithAttributes(attribute.String("golinks.link", link.Name)))
+	err := t.Store.CreateLink(ctx, link)
+	t.end(span, err)
+	return err
+}
+
+// GetLinkByName implements Store.
+func (t *traced) GetLinkByName(ctx context.Context, name string) (Link, error) {
+	ctx, span := t.start(ctx, "GetLinkByName", trace.WithAttributes(attribute.String("golinks.link", name)))
+	link, err := t.Store.GetLinkByName(ctx, name)
+	t.end(span, err)
+	return link, err
+}
+
+// GetLinkByURL implements Store.
+func (t *traced) GetLinkByURL(ctx context.Context, url string) (Link, error) {
+	ctx, span := t.start(ctx, "GetLinkByURL")
+	link, err := t.Store.GetLinkByURL(ctx, url)
+	t.end(span, err)
+	return link, err
+}
+
+// UpdateLink implements Store.
+func (t *traced) UpdateLink(ctx context.Context, name string, patch LinkPatch) error {
+	ctx, span := t.start(ctx, "UpdateLink", trace.WithAttributes(attribute.String("golinks.link", name)))
+	err := t.Store.UpdateLink(ctx, name, patch)
+	t.end(span, err)
+	return err
+}
+
+// DisableLink implements Store.
+func (t *traced) DisableLink(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "DisableLink", trace.WithAttributes(attribute.String("golinks.link", name)))
+	err := t.Store.DisableLink(ctx, name)
+	t.end(span, err)
+	return err
+}
+
+// RestoreLink implements Store.
+func (t *traced) RestoreLink(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "RestoreLink", trace.WithAttributes(attribute.String("golinks.link", name)))
+	err := t.Store.RestoreLink(ctx, name)
+	t.end(span, err)
+	return err
+}
+
+// PurgeLink implements Store.
+func (t *traced) PurgeLink(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "PurgeLink", trace.WithAttributes(attribute.String("golinks.link", name)))
+	err := t.Store.PurgeLink(ctx, name)
+	t.end(span, err)
+	return err
+}
+
+// PurgeDisabledLinks implements Store.
+func (t *traced) PurgeDisabledLinks(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := t.start(ctx, "PurgeDisabledLinks")
+	count, err := t.Store.PurgeDisabledLinks(ctx, before)
+	t.end(span, err)
+	return count, err
+}
+
+// GetDisabledLinks implements Store.
+func (t *traced) GetDisabledLinks(ctx context.Context, owners []string) ([]Link, error) {
+	ctx, span := t.start(ctx, "GetDisabledLinks")
+	links, err := t.Store.GetDisabledLinks(ctx, owners)
+	t.end(span, err)
+	return links, err
+}
+
+// GetPopularLinks implements Store.
+func (t *traced) GetPopularLinks(ctx context.Context, size int) ([]Link, error) {
+	ctx, span := t.start(ctx, "GetPopularLinks")
+	links, err := t.Store.GetPopularLinks(ctx, size)
+	t.end(span, err)
+	return links, err
+}
+
+// GetRecentLinks implements Store.
+func (t *traced) GetRecentLinks(ctx context.Context, size int) ([]Link, error) {
+	ctx, span := t.start(ctx, "GetRecentLinks")
+	links, err := t.Store.GetRecentLinks(ctx, size)
+	t.end(span, err)
+	return links, err
+}
+
+// GetOwnedLinks implements Store.
+func (t *traced) GetOwnedLinks(ctx context.Context, owners []string) ([]Link, error) {
+	ctx, span := t.start(ctx, "GetOwnedLinks")
+	links, err := t.Store.GetOwnedLinks(ctx, owners)
+	t.end(span, err)
+	return links, err
+}
+
+// IncrementLinkViews implements Store.
+func (t *traced) IncrementLinkViews(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "IncrementLinkViews", trace.WithAttributes(attribute.String("golinks.link", name)))
+	err := t.Store.IncrementLinkViews(ctx, name)
+	t.end(span, err)
+	return err
+}
+
+// AddLinkViews implements Store.
+func (t *traced) AddLinkViews(ctx context.Context, name string, delta int) error {
+	ctx, span := t.start(ctx, "AddLinkViews", trace.WithAttributes(attribute.String("golinks.link", name)))
+	err := t.Store.AddLinkViews(ctx, name, delta)
+	t.end(span, err)
+	return err
+}
+
+// QueryLinks implements Store.
+func (t *traced) QueryLinks(ctx context.Context, query string) ([]Link, error) {
+	ctx, span := t.start(ctx, "QueryLinks")
+	links, err := t.Store.QueryLinks(ctx, query)
+	t.end(span, err)
+	return links, err
+}
+
+// WalkLinks implements Store. The span includes the time spent in fn.
+func (t *traced) WalkLinks(ctx context.Context, fn func(Link) error) error {
+	ctx, span := t.start(ctx, "WalkLinks")
+	err := t.Store.WalkLinks(ctx, fn)
+	t.end(span, err)
+	return err
+}
+
+// ImportLink implements Store.
+func (t *traced) ImportLink(ctx context.Context, link Link, overwrite bool) error {
+	ctx, span := t.start(ctx, "ImportLink", trace.WithAttributes(attribute.String("golinks.link", link.Name)))
+	err := t.Store.ImportLink(ctx, link, overwrite)
+	t.end(span, err)
+	return err
+}
+
+// Ping implements Store.
+func (t *traced) Ping(ctx context.Context) error {
+	ctx, span := t.start(ctx, "Ping")
+	err := t.Store.Ping(ctx)
+	t.end(span, err)
+	return err
+}
+
+// Close implements Store.
+func (t *traced) Close(ctx context.Context) error {
+	ctx, span := t.start(ctx, "Close")
+	err := t.Store.Close(ctx)
+	t.end(span, err)
+	return err
+}
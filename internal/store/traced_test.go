@@ -0,0 +1,78 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/imdevinc/go-links/internal/store"
+	"github.com/imdevinc/go-links/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewTracedStore(store.NewMemoryStore(), sdktrace.NewTracerProvider())
+	})
+}
+
+func TestTracedStore(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s := store.NewTracedStore(failingStore{store.NewMemoryStore()}, provider)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	assert.NoError(t, s.CreateLink(ctx, store.Link{Name: "docs", URL: "https://example.com"}))
+	_, err := s.GetLinkByName(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrLinkNotFound)
+	assert.Error(t, s.UpdateLink(ctx, "docs", store.LinkPatch{}))
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 4) {
+		t.FailNow()
+	}
+	expected := []struct {
+		name   string
+		link   string
+		status codes.Code
+	}{
+		{"store.CreateLink", "docs", codes.Unset},
+		// missing links are answers, not errors
+		{"store.GetLinkByName", "missing", codes.Unset},
+		{"store.UpdateLink", "docs", codes.Error},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.name, spans[i].Name)
+		assert.Equal(t, e.status, spans[i].Status.Code, e.name)
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[i].Parent.SpanID(), e.name)
+		assert.Contains(t, spans[i].Attributes, attribute.String("golinks.link", e.link), e.name)
+	}
+}
+
+// traceDrivers makes an in-memory exporter the destination of the global
+// tracer provider used by the store drivers, until the test ends.
+func traceDrivers(t *testing.T) *tracetest.InMemoryExporter {
+	previous := otel.GetTracerProvider()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+// assertDriverSpans checks that the driver recorded client spans for system.
+func assertDriverSpans(t *testing.T, exporter *tracetest.InMemoryExporter, system string) {
+	found := false
+	for _, span := range exporter.GetSpans() {
+		for _, kv := range span.Attributes {
+			if kv.Key == "db.system" && kv.Value.AsString() == system {
+				found = true
+			}
+		}
+	}
+	assert.True(t, found, "no %s spans recorded", system)
+}